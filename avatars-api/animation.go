@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxAnimatedSize    = 512
+	animationFPS       = 10
+	maxAnimationFrames = 60
+)
+
+var (
+	durRegex    = regexp.MustCompile(`\bdur="([^"]+)"`)
+	numberRegex = regexp.MustCompile(`-?\d*\.?\d+`)
+	hexRegex    = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// svgElement is a parsed SVG element, kept just long enough to apply
+// animations to it. children holds *svgElement and escaped text.
+type svgElement struct {
+	name     string
+	attrs    []xml.Attr
+	children []any
+}
+
+// sampleAnimations freezes the SMIL animations of an SVG at time t (seconds):
+// every <animate>/<animateTransform> is evaluated, written onto its parent
+// element and removed. Only the subset the generators use is understood:
+// `values` lists with linear or discrete calcMode, `dur` and `begin` offsets.
+func sampleAnimations(svgContent string, t float64) string {
+	root := &svgElement{}
+	stack := []*svgElement{root}
+
+	decoder := xml.NewDecoder(strings.NewReader(svgContent))
+	for {
+		tok, err := decoder.RawToken()
+		if err != nil {
+			break
+		}
+		parent := stack[len(stack)-1]
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			el := &svgElement{name: qualifiedName(tok.Name), attrs: append([]xml.Attr(nil), tok.Attr...)}
+			parent.children = append(parent.children, el)
+			stack = append(stack, el)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			var buf bytes.Buffer
+			xml.EscapeText(&buf, tok)
+			parent.children = append(parent.children, buf.String())
+		}
+	}
+
+	var out strings.Builder
+	for _, child := range root.children {
+		writeSampled(&out, child, t)
+	}
+	return out.String()
+}
+
+func writeSampled(out *strings.Builder, node any, t float64) {
+	el, ok := node.(*svgElement)
+	if !ok {
+		out.WriteString(node.(string))
+		return
+	}
+
+	attrs := map[string]string{}
+	var order []string
+	for _, a := range el.attrs {
+		key := qualifiedName(a.Name)
+		attrs[key] = a.Value
+		order = append(order, key)
+	}
+	set := func(key, value string) {
+		if _, exists := attrs[key]; !exists {
+			order = append(order, key)
+		}
+		attrs[key] = value
+	}
+
+	var children []any
+	for _, child := range el.children {
+		anim, ok := child.(*svgElement)
+		if !ok || (anim.name != "animate" && anim.name != "animateTransform") {
+			children = append(children, child)
+			continue
+		}
+		a := attrMap(anim.attrs)
+		value := sampleValues(a, t)
+		if value == "" {
+			continue
+		}
+		if anim.name == "animateTransform" {
+			value = fmt.Sprintf("%s(%s)", a["type"], value)
+			if existing := attrs["transform"]; existing != "" {
+				value = existing + " " + value
+			}
+			set("transform", value)
+			continue
+		}
+		set(a["attributeName"], value)
+	}
+
+	out.WriteString("<" + el.name)
+	for _, key := range order {
+		fmt.Fprintf(out, ` %s="%s"`, key, xmlAttrEscape(attrs[key]))
+	}
+	out.WriteString(">")
+	for _, child := range children {
+		writeSampled(out, child, t)
+	}
+	out.WriteString("</" + el.name + ">")
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// sampleValues evaluates one animation element's `values` at time t.
+func sampleValues(a map[string]string, t float64) string {
+	values := strings.Split(a["values"], ";")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	dur := parseClock(a["dur"])
+	if len(values) == 0 || values[0] == "" || dur <= 0 {
+		return ""
+	}
+
+	p := math.Mod(t-parseClock(a["begin"]), dur) / dur
+	if p < 0 {
+		p++
+	}
+
+	if a["calcMode"] == "discrete" || len(values) == 1 {
+		return values[min(int(p*float64(len(values))), len(values)-1)]
+	}
+	pos := p * float64(len(values)-1)
+	i := min(int(pos), len(values)-2)
+	return interpolateValue(values[i], values[i+1], pos-float64(i))
+}
+
+// parseClock reads a SMIL clock value such as "2s", "-0.5s" or "150ms".
+func parseClock(v string) float64 {
+	v = strings.TrimSpace(v)
+	if strings.HasSuffix(v, "ms") {
+		f, _ := strconv.ParseFloat(strings.TrimSuffix(v, "ms"), 64)
+		return f / 1000
+	}
+	f, _ := strconv.ParseFloat(strings.TrimSuffix(v, "s"), 64)
+	return f
+}
+
+// interpolateValue blends two animation values: #rrggbb colors channel by
+// channel, anything else number by number keeping the text of from.
+func interpolateValue(from, to string, f float64) string {
+	if hexRegex.MatchString(from) && hexRegex.MatchString(to) {
+		a, _ := strconv.ParseUint(from[1:], 16, 32)
+		b, _ := strconv.ParseUint(to[1:], 16, 32)
+		var rgb [3]uint64
+		for i, shift := range []uint{16, 8, 0} {
+			ca, cb := float64(a>>shift&0xff), float64(b>>shift&0xff)
+			rgb[i] = uint64(math.Round(ca + (cb-ca)*f))
+		}
+		return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+	}
+
+	targets := numberRegex.FindAllString(to, -1)
+	i := 0
+	return numberRegex.ReplaceAllStringFunc(from, func(n string) string {
+		if i >= len(targets) {
+			return n
+		}
+		a, _ := strconv.ParseFloat(n, 64)
+		b, _ := strconv.ParseFloat(targets[i], 64)
+		i++
+		return strconv.FormatFloat(a+(b-a)*f, 'f', -1, 64)
+	})
+}
+
+// animationPeriod is the loop length of an animated SVG: its longest dur. The
+// generators only use durations that divide it, so the loop is seamless.
+func animationPeriod(svgContent string) float64 {
+	period := 0.0
+	for _, m := range durRegex.FindAllStringSubmatch(svgContent, -1) {
+		period = math.Max(period, parseClock(m[1]))
+	}
+	return period
+}
+
+// renderFrames samples an animated SVG at animationFPS over one period and
+// rasterizes every frame. A static SVG yields a single frame.
+func renderFrames(svgContent string, size int) ([]*image.RGBA, error) {
+	count := 1
+	if strings.Contains(svgContent, "<animate") {
+		count = int(math.Round(animationPeriod(svgContent) * animationFPS))
+		count = max(1, min(count, maxAnimationFrames))
+	}
+
+	frames := make([]*image.RGBA, 0, count)
+	for i := range count {
+		// Sample the middle of each frame so discrete steps never land on an edge.
+		t := (float64(i) + 0.5) / animationFPS
+		frame, err := renderAvatarImage(sampleAnimations(svgContent, t), size)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// encodeAnimation renders an avatar as an animated GIF or APNG.
+func encodeAnimation(svgContent string, size int, format string) ([]byte, error) {
+	frames, err := renderFrames(svgContent, size)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if format == "gif" {
+		err = encodeGIF(&buf, frames)
+	} else {
+		err = encodeAPNG(&buf, frames)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeGIF quantizes every frame to the Plan 9 palette with index 0 reserved
+// for transparency, so rounded and circular shapes keep their corners clear.
+func encodeGIF(w io.Writer, frames []*image.RGBA) error {
+	pal := append(color.Palette{color.Transparent}, palette.Plan9[:255]...)
+	anim := &gif.GIF{LoopCount: 0}
+	for _, frame := range frames {
+		paletted := image.NewPaletted(frame.Bounds(), pal)
+		draw.FloydSteinberg.Draw(paletted, frame.Bounds(), frame, image.Point{})
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, 100/animationFPS)
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// encodeAPNG writes an animated PNG. Each frame is encoded with image/png and
+// its IDAT data is rewrapped: the first frame keeps IDAT so viewers without
+// APNG support still show it, later frames become fdAT chunks.
+func encodeAPNG(w io.Writer, frames []*image.RGBA) error {
+	bounds := frames[0].Bounds()
+	var out bytes.Buffer
+	out.WriteString("\x89PNG\r\n\x1a\n")
+	seq := uint32(0)
+
+	for i, frame := range frames {
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, frame); err != nil {
+			return err
+		}
+		chunks, err := readPNGChunks(encoded.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			for _, c := range chunks {
+				if c.typ == "IHDR" {
+					writePNGChunk(&out, "IHDR", c.data)
+				}
+			}
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+			binary.BigEndian.PutUint32(actl[4:], 0) // loop forever
+			writePNGChunk(&out, "acTL", actl)
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(bounds.Dy()))
+		binary.BigEndian.PutUint16(fctl[20:], 1)
+		binary.BigEndian.PutUint16(fctl[22:], animationFPS)
+		fctl[24] = 1 // dispose to background
+		fctl[25] = 0 // replace, don't blend
+		writePNGChunk(&out, "fcTL", fctl)
+		seq++
+
+		for _, c := range chunks {
+			if c.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePNGChunk(&out, "IDAT", c.data)
+				continue
+			}
+			fdat := make([]byte, 4+len(c.data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			copy(fdat[4:], c.data)
+			writePNGChunk(&out, "fdAT", fdat)
+			seq++
+		}
+	}
+
+	writePNGChunk(&out, "IEND", nil)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+func readPNGChunks(b []byte) ([]pngChunk, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("png too short")
+	}
+	var chunks []pngChunk
+	for pos := 8; pos+12 <= len(b); {
+		n := int(binary.BigEndian.Uint32(b[pos:]))
+		if pos+12+n > len(b) {
+			return nil, fmt.Errorf("truncated png chunk")
+		}
+		chunks = append(chunks, pngChunk{typ: string(b[pos+4 : pos+8]), data: b[pos+8 : pos+8+n]})
+		pos += 12 + n
+	}
+	return chunks, nil
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	w.Write(length[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
+
+// smilAnimate formats an endlessly repeating <animate> element. A negative
+// begin starts the loop part-way through, which is how generators stagger
+// elements deterministically.
+func smilAnimate(attribute, values string, dur, begin float64, discrete bool) string {
+	mode := ""
+	if discrete {
+		mode = ` calcMode="discrete"`
+	}
+	return fmt.Sprintf(`<animate attributeName="%s" values="%s" dur="%gs" begin="%gs"%s repeatCount="indefinite" />`,
+		attribute, values, dur, begin, mode)
+}
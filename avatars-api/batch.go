@@ -0,0 +1,280 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxBatchItems     = 250
+	maxBatchBodyBytes = 1 << 20
+	// maxBatchPixels bounds the pixels a batch may rasterize, frames of
+	// animations included: 250 PNGs at 256px, or 17 animations at 128px.
+	// Without it one request could keep a core busy for minutes.
+	maxBatchPixels = 16 << 20
+)
+
+var (
+	batchIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	svgIDRegex   = regexp.MustCompile(`\bid="([^"]+)"`)
+	svgURLRegex  = regexp.MustCompile(`url\(#([^)]+)\)`)
+)
+
+// batchItem is one avatar of a batch request. Parameters other than name, type
+// and size are taken from the batch's query string and apply to every item.
+type batchItem struct {
+	ID   string      `json:"id"`
+	Name string      `json:"name"`
+	Type string      `json:"type"`
+	Size json.Number `json:"size"`
+}
+
+// batchResult is a rendered item together with the ID it is published under.
+type batchResult struct {
+	ID     string
+	Avatar *renderedAvatar
+}
+
+// batchAvatarsHandler renders a list of avatars in one request, as an SVG
+// sprite with a <symbol> per avatar, a ZIP of files, or a JSON map of data URIs.
+// Every item goes through renderAvatar, so the response cache is consulted per item.
+func batchAvatarsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	output := strings.ToLower(query.Get("output"))
+	if output == "" {
+		output = "sprite"
+	}
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = "svg"
+	}
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	query.Del("output")
+	query.Del("format")
+
+	switch output {
+	case "sprite":
+		if format != "svg" {
+			writeAvatarError(w, badRequest("format", "A sprite can only hold SVG avatars. Use output=zip or output=json for raster formats."))
+			return
+		}
+	case "zip", "json":
+		if _, ok := rasterFormats[format]; !ok && format != "svg" {
+			writeAvatarError(w, badRequest("format", "Invalid format for a batch. Use 'svg', 'png', 'webp', 'jpeg', 'gif' or 'apng'."))
+			return
+		}
+	default:
+		writeAvatarError(w, badRequest("output", "Invalid output. Use 'sprite', 'zip' or 'json'."))
+		return
+	}
+
+	var items []batchItem
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&items); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Batch body is too large. The limit is %d bytes.", maxBatchBodyBytes), nil)
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "Invalid batch body. Send a JSON array of {\"id\", \"name\", \"type\", \"size\"} objects.", nil)
+		return
+	}
+	if len(items) == 0 || len(items) > maxBatchItems {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("A batch must contain between 1 and %d avatars.", maxBatchItems), nil)
+		return
+	}
+
+	if pixels := batchPixels(items, query, format); pixels > maxBatchPixels {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Batch is too large to render: %d megapixels, over the limit of %d. Use fewer or smaller avatars, or don't animate them.", (pixels+1<<20-1)>>20, maxBatchPixels>>20), nil)
+		return
+	}
+
+	results := make([]batchResult, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	hits := 0
+	for i, item := range items {
+		// Stop rendering for a client that has gone away.
+		if r.Context().Err() != nil {
+			return
+		}
+		id := item.ID
+		if id == "" {
+			id = fmt.Sprintf("avatar-%d", i)
+		}
+		if !batchIDRegex.MatchString(id) {
+			writeAvatarError(w, badRequest(fmt.Sprintf("items[%d].id", i), "Item %d: invalid id. Use up to 64 letters, digits, '-' or '_'.", i))
+			return
+		}
+		if seen[id] {
+			writeAvatarError(w, badRequest(fmt.Sprintf("items[%d].id", i), "Item %d: duplicate id %q.", i, id))
+			return
+		}
+		seen[id] = true
+
+		itemQuery := cloneQuery(query)
+		if item.Name != "" {
+			itemQuery.Set("name", item.Name)
+		}
+		if item.Type != "" {
+			itemQuery.Set("type", item.Type)
+		}
+		if item.Size != "" {
+			itemQuery.Set("size", item.Size.String())
+		}
+
+		avatar, err := renderAvatar(r.Context(), itemQuery, format)
+		if err != nil {
+			var e *avatarError
+			if errors.As(err, &e) && e.Status == http.StatusBadRequest {
+				// Report the item's parameters as items[i].param.
+				fields := make(fieldErrors, len(e.Fields))
+				for j, f := range e.Fields {
+					fields[j] = fieldError{Param: fmt.Sprintf("items[%d].%s", i, f.Param), Message: f.Message}
+				}
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Item %d (%s): %s", i, id, e.Message), fields)
+				return
+			}
+			writeAvatarError(w, err)
+			return
+		}
+		if avatar.CacheHit {
+			hits++
+		}
+		results = append(results, batchResult{ID: id, Avatar: avatar})
+	}
+
+	var body []byte
+	contentType := "application/json"
+	switch output {
+	case "sprite":
+		body = buildSprite(results)
+		contentType = "image/svg+xml; charset=utf-8"
+	case "zip":
+		var err error
+		if body, err = buildZip(results, format); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to build archive", nil)
+			return
+		}
+		contentType = "application/zip"
+		w.Header().Set("Content-Disposition", `attachment; filename="avatars.zip"`)
+	case "json":
+		uris := make(map[string]string, len(results))
+		for _, res := range results {
+			uris[res.ID] = dataURI(res.Avatar.ContentType, res.Avatar.Body)
+		}
+		var err error
+		if body, err = json.Marshal(uris); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to encode avatars", nil)
+			return
+		}
+	}
+
+	w.Header().Set("X-Cache-Hits", fmt.Sprintf("%d/%d", hits, len(results)))
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// batchPixels estimates how many pixels rendering items as format takes. SVG
+// output costs nothing here. Animations count as their longest possible run of
+// frames, since the period is only known once the SVG is built. Sizes that
+// fail to parse count as zero; rendering rejects them anyway.
+func batchPixels(items []batchItem, query url.Values, format string) int {
+	if _, raster := rasterFormats[format]; !raster {
+		return 0
+	}
+	frames := 1
+	if animate, _ := strconv.ParseBool(query.Get("animate")); animate && (format == "gif" || format == "apng") {
+		frames = maxAnimationFrames
+	}
+	total := 0
+	for _, item := range items {
+		raw := item.Size.String()
+		if raw == "" {
+			raw = cmp.Or(query.Get("size"), "100")
+		}
+		n, _ := strconv.Atoi(raw)
+		n = max(0, min(n, maxRasterSize))
+		total += n * n * frames
+	}
+	return total
+}
+
+func cloneQuery(query url.Values) url.Values {
+	clone := make(url.Values, len(query))
+	for k, v := range query {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// buildSprite wraps every avatar in a <symbol> named after its ID. IDs inside
+// an avatar (gradients, clip paths, ...) are prefixed with the symbol ID so
+// avatars of the same style don't overwrite each other's definitions.
+func buildSprite(results []batchResult) []byte {
+	var sprite bytes.Buffer
+	sprite.WriteString(`<svg xmlns="http://www.w3.org/2000/svg">`)
+	for _, res := range results {
+		svgContent := string(res.Avatar.Body)
+		root := svgRootRegex.FindStringIndex(svgContent)
+		end := strings.LastIndex(svgContent, "</svg>")
+		if root == nil || end < root[1] {
+			continue
+		}
+		viewBox := ""
+		if m := viewBoxRegex.FindStringSubmatch(svgContent[root[0]:root[1]]); m != nil {
+			viewBox = m[1]
+		}
+		inner := prefixSVGIDs(svgContent[root[1]:end], res.ID)
+
+		fmt.Fprintf(&sprite, `<symbol id="%s" viewBox="%s">%s</symbol>`, res.ID, viewBox, inner)
+	}
+	sprite.WriteString(`</svg>`)
+	return sprite.Bytes()
+}
+
+// dataURI embeds body in a data: URI. Spaces are dropped from the content type,
+// which data: URIs don't allow.
+func dataURI(contentType string, body []byte) string {
+	return "data:" + strings.ReplaceAll(contentType, " ", "") + ";base64," + base64.StdEncoding.EncodeToString(body)
+}
+
+// prefixSVGIDs prefixes the IDs defined in markup, and the url(#...) references
+// to them, so several avatars can share one document.
+func prefixSVGIDs(markup, prefix string) string {
+	markup = svgIDRegex.ReplaceAllString(markup, `id="`+prefix+`-$1"`)
+	return svgURLRegex.ReplaceAllString(markup, `url(#`+prefix+`-$1)`)
+}
+
+func buildZip(results []batchResult, format string) ([]byte, error) {
+	ext := format
+	if ext == "jpeg" {
+		ext = "jpg"
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, res := range results {
+		f, err := archive.Create(res.ID + "." + ext)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(res.Avatar.Body); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultStarMagnitude is used for figure stars missing from brightStars;
+	// most asterism stars not in the table are around fourth magnitude.
+	defaultStarMagnitude = 4.0
+	starMatchTolerance   = 0.05 // degrees
+)
+
+var constellationsOnce sync.Once
+
+// constellationFeatures returns the embedded asterisms, parsing them on first
+// use. Generators run concurrently, so they must go through here rather than
+// calling loadGeoJSON themselves.
+func constellationFeatures() []Feature {
+	constellationsOnce.Do(loadGeoJSON)
+	return constellationData.Features
+}
+
+// findConstellation resolves the constellation parameter, matched without
+// regard to case or spaces against both the ID and the name of a figure.
+func findConstellation(query string) (*Feature, bool) {
+	key := constellationKey(query)
+	features := constellationFeatures()
+	for i := range features {
+		f := &features[i]
+		if constellationKey(f.ID) == key || constellationKey(f.Properties.Name) == key {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func constellationKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_', '\'':
+			return -1
+		}
+		return r
+	}, strings.ToLower(s))
+}
+
+// star is a catalogue entry: J2000 right ascension and declination in degrees
+// and apparent visual magnitude.
+type star struct {
+	Name string
+	RA   float64
+	Dec  float64
+	Mag  float64
+}
+
+// brightStars holds the magnitudes of the named stars drawn by the embedded
+// asterisms. constellations.json only has line coordinates, so stars are
+// matched to figure vertices by position.
+var brightStars = []star{
+	{"Sirius", 101.287, -16.716, -1.46},
+	{"Canopus", 95.988, -52.696, -0.74},
+	{"Rigil Kentaurus", 219.896, -60.837, -0.27},
+	{"Arcturus", 213.915, 19.182, -0.05},
+	{"Vega", 279.235, 38.784, 0.03},
+	{"Capella", 79.172, 45.998, 0.08},
+	{"Rigel", 78.635, -8.202, 0.13},
+	{"Procyon", 114.826, 5.225, 0.34},
+	{"Betelgeuse", 88.793, 7.407, 0.50},
+	{"Hadar", 210.956, -60.373, 0.61},
+	{"Altair", 297.696, 8.868, 0.76},
+	{"Acrux", 186.650, -63.099, 0.77},
+	{"Aldebaran", 68.980, 16.509, 0.86},
+	{"Spica", 201.298, -11.161, 0.97},
+	{"Antares", 247.352, -26.432, 1.06},
+	{"Pollux", 116.329, 28.026, 1.14},
+	{"Deneb", 310.358, 45.280, 1.25},
+	{"Mimosa", 191.930, -59.689, 1.25},
+	{"Regulus", 152.093, 11.967, 1.35},
+	{"Shaula", 263.402, -37.104, 1.62},
+	{"Gacrux", 187.792, -57.113, 1.64},
+	{"Miaplacidus", 138.300, -69.717, 1.68},
+	{"Alnilam", 84.053, -1.202, 1.69},
+	{"Alnitak", 85.190, -1.943, 1.77},
+	{"Alioth", 193.507, 55.960, 1.77},
+	{"Dubhe", 165.932, 61.751, 1.79},
+	{"Mirfak", 51.081, 49.861, 1.79},
+	{"Regor", 122.383, -47.337, 1.83},
+	{"Kaus Australis", 276.043, -34.385, 1.85},
+	{"Avior", 125.629, -59.510, 1.86},
+	{"Alkaid", 206.885, 49.313, 1.86},
+	{"Sargas", 264.330, -42.998, 1.86},
+	{"Atria", 252.166, -69.028, 1.91},
+	{"Alsephina", 131.176, -54.709, 1.96},
+	{"Polaris", 37.955, 89.264, 1.98},
+	{"Mirach", 17.433, 35.621, 2.05},
+	{"Nunki", 283.816, -26.297, 2.05},
+	{"Alpheratz", 2.097, 29.090, 2.06},
+	{"Rasalhague", 263.734, 12.560, 2.07},
+	{"Kochab", 222.676, 74.156, 2.08},
+	{"Algieba", 154.993, 19.841, 2.08},
+	{"Algol", 47.042, 40.956, 2.12},
+	{"Denebola", 177.265, 14.572, 2.13},
+	{"Aspidiske", 139.273, -59.275, 2.21},
+	{"Suhail", 136.999, -43.433, 2.21},
+	{"Eltanin", 269.151, 51.489, 2.23},
+	{"Mintaka", 83.002, -0.299, 2.23},
+	{"Mizar", 200.981, 54.925, 2.23},
+	{"Sadr", 305.557, 40.257, 2.23},
+	{"Schedar", 10.127, 56.537, 2.24},
+	{"Naos", 120.896, -40.003, 2.25},
+	{"Almach", 30.975, 42.330, 2.26},
+	{"Caph", 2.295, 59.150, 2.28},
+	{"Dschubba", 240.083, -22.622, 2.29},
+	{"Larawag", 252.541, -34.293, 2.29},
+	{"Merak", 165.460, 56.382, 2.37},
+	{"Izar", 221.247, 27.074, 2.37},
+	{"Girtab", 265.622, -39.030, 2.39},
+	{"Scheat", 345.944, 28.083, 2.42},
+	{"Sabik", 257.594, -15.725, 2.43},
+	{"Phecda", 178.458, 53.695, 2.44},
+	{"Alderamin", 319.645, 62.586, 2.45},
+	{"Gamma Cassiopeiae", 14.177, 60.717, 2.47},
+	{"Markeb", 140.528, -55.011, 2.47},
+	{"Aljanah", 311.553, 33.970, 2.48},
+	{"Markab", 346.190, 15.205, 2.49},
+	{"Menkar", 45.570, 4.090, 2.53},
+	{"Zeta Ophiuchi", 249.290, -10.567, 2.56},
+	{"Gienah", 183.952, -17.542, 2.59},
+	{"Ascella", 285.653, -29.880, 2.60},
+	{"Acrab", 241.359, -19.805, 2.62},
+	{"Kraz", 188.597, -23.397, 2.65},
+	{"Ruchbah", 21.454, 60.235, 2.68},
+	{"Muphrid", 208.671, 18.398, 2.68},
+	{"Mu Velorum", 161.692, -49.420, 2.69},
+	{"Kaus Media", 275.249, -29.828, 2.70},
+	{"Ahadi", 109.286, -37.097, 2.70},
+	{"Porrima", 190.415, -1.449, 2.74},
+	{"Theta Carinae", 160.739, -64.394, 2.76},
+	{"Cebalrai", 265.868, 4.567, 2.76},
+	{"Hatysa", 83.858, -5.910, 2.77},
+	{"Imai", 183.786, -58.749, 2.79},
+	{"Rastaban", 262.608, 52.301, 2.79},
+	{"Kaus Borealis", 276.993, -25.422, 2.81},
+	{"Tureis", 121.886, -24.304, 2.81},
+	{"Zeta Herculis", 250.322, 31.603, 2.81},
+	{"Paikauhale", 248.971, -28.216, 2.82},
+	{"Algenib", 3.309, 15.184, 2.83},
+	{"Vindemiatrix", 195.544, 10.959, 2.83},
+	{"Beta Trianguli Australis", 238.786, -63.431, 2.85},
+	{"Gamma Trianguli Australis", 229.727, -68.680, 2.87},
+	{"Fawaris", 296.244, 45.131, 2.87},
+	{"Albaldah", 287.441, -21.024, 2.88},
+	{"Alniyat", 245.297, -25.593, 2.89},
+	{"Cor Caroli", 194.007, 38.318, 2.90},
+	{"Tau Puppis", 99.440, -43.196, 2.93},
+	{"Algorab", 187.466, -16.515, 2.95},
+	{"Upsilon Carinae", 146.775, -65.072, 2.97},
+	{"Alnasl", 271.452, -30.424, 2.98},
+	{"Ras Elased Australis", 146.463, 23.774, 2.98},
+	{"Almaaz", 75.492, 43.823, 2.99},
+	{"Iota Scorpii", 266.896, -40.127, 2.99},
+	{"Minkar", 182.531, -22.620, 3.00},
+	{"Seginus", 218.019, 38.308, 3.03},
+	{"Xamidimura", 252.968, -38.047, 3.04},
+	{"Pherkad", 230.182, 71.834, 3.05},
+	{"Albireo", 292.680, 27.960, 3.05},
+	{"Lambda Velorum", 153.684, -42.122, 3.13},
+	{"Pi Herculis", 258.762, 36.809, 3.16},
+	{"Phi Sagittarii", 281.414, -26.991, 3.17},
+	{"Haedus", 76.629, 41.234, 3.17},
+	{"Kappa Ophiuchi", 254.417, 9.375, 3.20},
+	{"Errai", 354.837, 77.632, 3.21},
+	{"Alfirk", 322.165, 70.561, 3.23},
+	{"Megrez", 183.857, 57.033, 3.31},
+	{"Tau Sagittarii", 286.735, -27.670, 3.32},
+	{"Eta Scorpii", 258.038, -43.239, 3.32},
+	{"Omega Carinae", 153.434, -70.038, 3.32},
+	{"Zeta Cephei", 332.714, 58.201, 3.35},
+	{"Segin", 28.599, 63.670, 3.37},
+	{"Epsilon Hydrae", 131.694, 6.419, 3.38},
+	{"Rho Persei", 46.294, 38.840, 3.39},
+	{"Adhafera", 154.173, 23.417, 3.44},
+	{"Delta Bootis", 228.876, 33.315, 3.46},
+	{"Kaffaljidhma", 40.825, 3.236, 3.47},
+	{"Nekkar", 225.487, 40.391, 3.49},
+	{"Eta Leonis", 151.833, 16.763, 3.49},
+	{"Eta Herculis", 250.724, 38.922, 3.53},
+	{"Rho Bootis", 217.958, 30.371, 3.58},
+	{"Zeta2 Scorpii", 253.646, -42.361, 3.62},
+	{"Rotanev", 309.387, 14.595, 3.63},
+	{"Zeta Aquarii", 337.208, -0.020, 3.65},
+	{"Gamma Piscium", 349.291, 3.282, 3.69},
+	{"Saclateni", 75.620, 41.076, 3.75},
+	{"Grumium", 268.382, 56.873, 3.75},
+	{"Sualocin", 309.910, 15.912, 3.77},
+	{"Sigma Orionis", 84.687, -2.600, 3.80},
+	{"Lambda Andromedae", 354.391, 46.458, 3.82},
+	{"Praecipua", 163.328, 34.215, 3.83},
+	{"Sadachbia", 335.414, -1.387, 3.84},
+	{"Rasalas", 148.191, 26.007, 3.88},
+	{"Epsilon Herculis", 255.072, 30.926, 3.92},
+	{"Asellus Australis", 131.171, 18.154, 3.94},
+	{"Epsilon Ursae Minoris", 251.493, 82.037, 4.23},
+	{"Zeta Ursae Minoris", 236.015, 77.794, 4.32},
+	{"Delta Ursae Minoris", 263.054, 86.587, 4.36},
+}
+
+// starMagnitude looks up the magnitude of the star at a figure vertex. The
+// GeoJSON stores right ascension in [-180, 180].
+func starMagnitude(point []float64) float64 {
+	ra := math.Mod(point[0]+360, 360)
+	for _, s := range brightStars {
+		if math.Abs(s.RA-ra) < starMatchTolerance && math.Abs(s.Dec-point[1]) < starMatchTolerance {
+			return s.Mag
+		}
+	}
+	return defaultStarMagnitude
+}
+
+// starRadius maps a magnitude to a dot radius in viewBox units: each
+// magnitude is a third of a unit, so Sirius is about three times the size of
+// a fourth-magnitude star.
+func starRadius(mag float64) float64 {
+	return math.Max(0.6, math.Min(2.8, 2.3-mag/3))
+}
+
+// constellationInfo is one entry of GET /api/constellations.
+type constellationInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Spanish string `json:"es,omitempty"`
+	Stars   int    `json:"stars"`
+}
+
+// constellationsHandler lists the figures the constellation style can draw.
+// Some figures are split across several features; they are listed once, and
+// stars shared by two lines are counted once.
+func constellationsHandler(w http.ResponseWriter, r *http.Request) {
+	var list []constellationInfo
+	index := map[string]int{}
+	stars := map[string]map[[2]float64]bool{}
+	for _, f := range constellationFeatures() {
+		if _, ok := index[f.ID]; !ok {
+			index[f.ID] = len(list)
+			list = append(list, constellationInfo{ID: f.ID, Name: f.Properties.Name, Spanish: f.Properties.Spanish})
+			stars[f.ID] = map[[2]float64]bool{}
+		}
+		for _, line := range f.Geometry.Coordinates {
+			for _, p := range line {
+				stars[f.ID][[2]float64{p[0], p[1]}] = true
+			}
+		}
+	}
+	for i := range list {
+		list[i].Stars = len(stars[list[i].ID])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(list)
+}
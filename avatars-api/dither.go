@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/negotiate"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
+	"golang.org/x/image/colornames"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// ditherVersion is reported in X-Avatar-Version and bumped whenever the
+	// output for the same image and parameters changes.
+	ditherVersion      = 1
+	maxSourceBytes     = 5 << 20
+	maxSourcePixels    = 25_000_000
+	maxSourceURLLength = 2048
+	maxSourceRedirects = 3
+	sourceFetchTimeout = 10 * time.Second
+	defaultDitherGrid  = 64
+	minDitherGrid      = 8
+	maxDitherGrid      = 128
+	defaultDitherInk   = "#f8f8f2"
+	defaultDitherPaper = "#11011D"
+)
+
+var errSourceTooLarge = &avatarError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("Image is too large. The limit is %d bytes.", maxSourceBytes)}
+
+var ditherAlgorithms = []string{"bayer4", "bayer8", "floyd-steinberg", "atkinson"}
+
+var ditherOffers = []negotiate.Offer{
+	{Format: "svg", MediaType: "image/svg+xml"},
+	{Format: "png", MediaType: "image/png"},
+	{Format: "webp", MediaType: "image/webp"},
+	{Format: "jpeg", MediaType: "image/jpeg"},
+	{Format: "gif", MediaType: "image/gif"},
+}
+
+var bayerMatrix8x8 = [8][8]float64{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// diffusionStep spreads part of a pixel's quantization error to a neighbour.
+type diffusionStep struct {
+	dx, dy int
+	weight float64
+}
+
+var diffusionKernels = map[string][]diffusionStep{
+	"floyd-steinberg": {{1, 0, 7.0 / 16}, {-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16}},
+	// Atkinson only passes on 6/8 of the error, which keeps highlights and
+	// shadows clean.
+	"atkinson": {{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8}, {-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8}, {0, 2, 1.0 / 8}},
+}
+
+// ditherHandler serves /api/dither: GET with src=<url> fetches a remote image,
+// POST takes an upload, either as multipart/form-data (field "image") or as
+// the raw body. The image is cropped to a square, shrunk to a grid of cells
+// and dithered onto a palette, then goes through the same shape, overlay and
+// format handling as a generated avatar.
+func ditherHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	d, err := parseDitherOptions(r.URL.Query(), negotiate.Format(r, ditherOffers))
+	if err != nil {
+		writeAvatarError(w, err)
+		return
+	}
+
+	var source []byte
+	if r.Method == http.MethodPost {
+		source, err = readUploadedImage(w, r)
+	} else {
+		source, err = fetchSourceImage(r.Context(), r.URL.Query().Get("src"))
+	}
+	if err != nil {
+		writeAvatarError(w, err)
+		return
+	}
+
+	avatar, err := renderDither(r.Context(), source, d)
+	if err != nil {
+		writeAvatarError(w, err)
+		return
+	}
+	writeAvatar(w, avatar)
+}
+
+// readUploadedImage reads an uploaded image of at most maxSourceBytes.
+func readUploadedImage(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSourceBytes+64<<10)
+	body := io.Reader(r.Body)
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		part, err := findImagePart(r.Body, params["boundary"])
+		if err != nil {
+			return nil, uploadError(err)
+		}
+		body = part
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxSourceBytes+1))
+	if err != nil {
+		return nil, uploadError(err)
+	}
+	if len(data) == 0 {
+		return nil, badRequest("image", "No image uploaded. Send it as the request body or as the \"image\" field of a multipart form.")
+	}
+	if len(data) > maxSourceBytes {
+		return nil, errSourceTooLarge
+	}
+	return data, nil
+}
+
+// findImagePart returns the "image" field of a multipart form.
+func findImagePart(body io.Reader, boundary string) (io.Reader, error) {
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New(`no "image" field in the form`)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "image" {
+			return part, nil
+		}
+	}
+}
+
+func uploadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errSourceTooLarge
+	}
+	return badRequest("image", "Invalid upload: %v.", err)
+}
+
+// fetchSourceImage downloads src for dithering. Only http and https on their
+// default ports are allowed, the address every connection dials must be a
+// public one (see sourceDialControl), and the body is capped at
+// maxSourceBytes.
+func fetchSourceImage(ctx context.Context, src string) ([]byte, error) {
+	if src == "" {
+		return nil, badRequest("src", "Missing src. Pass the URL of an image, or POST the image instead.")
+	}
+	u, err := checkSourceURL(src)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sourceFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, badRequest("src", "Invalid src URL.")
+	}
+	req.Header.Set("Accept", "image/png, image/jpeg, image/gif, image/webp")
+	req.Header.Set("User-Agent", "utils-hub-avatars/1.0")
+
+	resp, err := sourceClient.Do(req)
+	if err != nil {
+		logging.From(ctx).Warn("dither source fetch failed", "host", u.Host, "err", err)
+		return nil, &avatarError{Status: http.StatusBadGateway, Message: "Couldn't fetch the src image. It must be a public http(s) URL."}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &avatarError{Status: http.StatusBadGateway, Message: fmt.Sprintf("Fetching the src image returned %d.", resp.StatusCode)}
+	}
+	if resp.ContentLength > maxSourceBytes {
+		return nil, errSourceTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceBytes+1))
+	if err != nil {
+		return nil, &avatarError{Status: http.StatusBadGateway, Message: "Couldn't read the src image."}
+	}
+	if len(data) > maxSourceBytes {
+		return nil, errSourceTooLarge
+	}
+	return data, nil
+}
+
+// checkSourceURL rejects URLs that could never be fetched safely, before any
+// DNS lookup. The address itself is checked when dialing.
+func checkSourceURL(raw string) (*url.URL, error) {
+	if len(raw) > maxSourceURLLength {
+		return nil, badRequest("src", "src is too long. Use at most %d bytes.", maxSourceURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, badRequest("src", "Invalid src. Use an absolute http or https URL.")
+	}
+	if u.User != nil {
+		return nil, badRequest("src", "src can't contain credentials.")
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		return nil, badRequest("src", "src must use the default http or https port.")
+	}
+	return u, nil
+}
+
+var sourceClient = &http.Client{
+	Timeout: sourceFetchTimeout,
+	Transport: metrics.Transport("dither_source", tracing.Transport(&http.Transport{
+		// No proxy: the dialer's address check must see the real destination.
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: sourceDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout:    5 * time.Second,
+		ResponseHeaderTimeout:  5 * time.Second,
+		MaxResponseHeaderBytes: 64 << 10,
+	})),
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxSourceRedirects {
+			return errors.New("too many redirects")
+		}
+		_, err := checkSourceURL(req.URL.String())
+		return err
+	},
+}
+
+// blockedPrefixes are ranges that aren't covered by the netip predicates in
+// isPublicAddr but still aren't reachable public hosts.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// sourceDialControl runs after DNS resolution, for every connection including
+// redirects, so a hostname can't resolve (or rebind) to an internal address.
+func sourceDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addr) {
+		return fmt.Errorf("refusing to connect to non-public address %s", addr)
+	}
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// ditherOptions is a validated /api/dither request, apart from the image.
+type ditherOptions struct {
+	Algorithm   string
+	Grid        int
+	Format      string
+	ContentType string
+	Pixels      int
+	Avatar      AvatarOptions
+}
+
+// parseDitherOptions validates the query of /api/dither, before any image is
+// fetched or read.
+func parseDitherOptions(query url.Values, format string) (ditherOptions, error) {
+	if format == "jpg" {
+		format = "jpeg"
+	}
+
+	var errs fieldErrors
+	d := ditherOptions{Algorithm: strings.ToLower(query.Get("algorithm")), Grid: defaultDitherGrid}
+	if d.Algorithm == "" {
+		d.Algorithm = "bayer4"
+	}
+	if !contains(ditherAlgorithms, d.Algorithm) {
+		errs.add("algorithm", "Invalid algorithm. Use one of: %s.", strings.Join(ditherAlgorithms, ", "))
+	}
+	if raw := query.Get("grid"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < minDitherGrid || n > maxDitherGrid {
+			errs.add("grid", "Invalid grid. Use an integer number of cells between %d and %d.", minDitherGrid, maxDitherGrid)
+		}
+		d.Grid = n
+	}
+
+	color := query.Get("color")
+	if color != "" {
+		var ok bool
+		if color, ok = parseColor(color); !ok {
+			errs.add("color", "Invalid color. Use a hex color such as #ff5733 or an SVG color name.")
+		}
+	}
+	opts, customErrs := parseCustomization(query)
+	errs = append(errs, customErrs...)
+	if len(opts.Group) > 0 {
+		errs.add("group", "group doesn't apply to a dithered image.")
+	}
+	if opts.Animate {
+		errs.add("animate", "A dithered image can't be animated.")
+	}
+
+	contentType := "image/svg+xml; charset=utf-8"
+	if format != "svg" {
+		var ok bool
+		if contentType, ok = rasterFormats[format]; !ok || format == "apng" {
+			errs.add("format", "Invalid format. Use 'svg', 'png', 'webp', 'jpeg' or 'gif'.")
+		}
+	}
+	size := query.Get("size")
+	if size == "" {
+		size = "100"
+	}
+	pixels := parseSize(size, format, false, &errs)
+	if err := errs.err(); err != nil {
+		return d, err
+	}
+	opts.Size, opts.Color = strconv.Itoa(pixels), color
+	d.Format, d.ContentType, d.Pixels, d.Avatar = format, contentType, pixels, opts
+	return d, nil
+}
+
+// renderDither dithers source. The output is cached by a hash of the image
+// bytes, so a popular src is only dithered once.
+func renderDither(ctx context.Context, source []byte, d ditherOptions) (*renderedAvatar, error) {
+	ctx, span := tracing.Start(ctx, "render dither")
+	defer span.End()
+
+	format, contentType, pixels, opts := d.Format, d.ContentType, d.Pixels, d.Avatar
+	sum := sha256.Sum256(source)
+	key := cacheKey("dither", hex.EncodeToString(sum[:]), format, pixels,
+		d.Grid, d.Algorithm, opts.Color, opts.Palette, opts.Background, opts.Shape, opts.Radius,
+		opts.Status, opts.Badge, opts.Ring, opts.RingWidth)
+	if cached, found := avatarCache.Get(key); found {
+		return &renderedAvatar{Body: cached, ContentType: contentType, Version: ditherVersion, CacheHit: true}, nil
+	}
+
+	img, err := decodeSourceImage(source)
+	if err != nil {
+		return nil, err
+	}
+	svgContent := applyOverlays(applyShape(ditherSVG(img, d), opts), opts)
+	if err := checkSVG(svgContent); err != nil {
+		logging.From(ctx).Error("unsafe dither markup", "err", err)
+		return nil, &avatarError{Status: http.StatusInternalServerError, Message: "Failed to render avatar"}
+	}
+
+	body := []byte(svgContent)
+	if format != "svg" {
+		_, span := tracing.Start(ctx, "rasterize "+format)
+		body, err = rasterizeAvatar(svgContent, pixels, format)
+		tracing.End(span, err)
+		if err != nil {
+			logging.From(ctx).Error("rasterize failed", "style", "dither", "format", format, "err", err)
+			return nil, &avatarError{Status: http.StatusInternalServerError, Message: "Failed to render avatar"}
+		}
+	}
+	avatarCache.Set(key, body)
+	return &renderedAvatar{Body: body, ContentType: contentType, Version: ditherVersion}, nil
+}
+
+// decodeSourceImage decodes a PNG, JPEG, GIF (first frame) or WebP image,
+// checking its dimensions first so a small file can't expand into a huge
+// bitmap.
+func decodeSourceImage(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, badRequest("image", "Unsupported image. Use PNG, JPEG, GIF or WebP.")
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, badRequest("image", "Image dimensions are too large. The limit is %d pixels.", maxSourcePixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, badRequest("image", "Couldn't decode the image: %v.", err)
+	}
+	return img, nil
+}
+
+// ditherSVG crops img to a centered square, shrinks it to a grid of cells and
+// dithers each cell's brightness across the palette, which is sorted from dark
+// to light like a gradient map. The palette is the palette parameter, or bg
+// and color.
+func ditherSVG(img image.Image, d ditherOptions) string {
+	o := d.Avatar
+	palette := o.Palette
+	if len(palette) == 0 {
+		palette = []string{o.background(defaultDitherPaper), o.accent(defaultDitherInk)}
+	}
+	if len(palette) == 1 {
+		palette = append(palette, o.background(defaultDitherPaper))
+	}
+	palette = slices.Clone(palette)
+	slices.SortStableFunc(palette, func(a, b string) int {
+		la, lb := hexLuminance(a), hexLuminance(b)
+		switch {
+		case la < lb:
+			return -1
+		case la > lb:
+			return 1
+		}
+		return 0
+	})
+
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(b.Min).Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+	cells := image.NewNRGBA(image.Rect(0, 0, d.Grid, d.Grid))
+	xdraw.CatmullRom.Scale(cells, cells.Bounds(), img, crop, xdraw.Src, nil)
+
+	levels := float64(len(palette) - 1)
+	lum := make([]float64, d.Grid*d.Grid)
+	opaque := make([]bool, d.Grid*d.Grid)
+	for y := range d.Grid {
+		for x := range d.Grid {
+			c := cells.NRGBAAt(x, y)
+			lum[y*d.Grid+x] = (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+			opaque[y*d.Grid+x] = c.A >= 128
+		}
+	}
+
+	index := make([]int, d.Grid*d.Grid)
+	kernel := diffusionKernels[d.Algorithm]
+	for y := range d.Grid {
+		for x := range d.Grid {
+			i := y*d.Grid + x
+			v := lum[i] * levels
+			var level float64
+			switch d.Algorithm {
+			case "bayer4":
+				level = math.Round(v + bayerMatrix4x4[y%4][x%4]/16 - 0.5 + 1.0/32)
+			case "bayer8":
+				level = math.Round(v + bayerMatrix8x8[y%8][x%8]/64 - 0.5 + 1.0/128)
+			default:
+				level = math.Round(v)
+			}
+			level = math.Max(0, math.Min(levels, level))
+			index[i] = int(level)
+
+			if kernel != nil && opaque[i] {
+				diff := (v - level) / levels
+				for _, step := range kernel {
+					nx, ny := x+step.dx, y+step.dy
+					if nx >= 0 && nx < d.Grid && ny < d.Grid {
+						lum[ny*d.Grid+nx] += diff * step.weight
+					}
+				}
+			}
+		}
+	}
+
+	// One path per color, with a subpath per run of equal cells in a row.
+	// Unlike separate rects, a path leaves no anti-aliased seams between rows.
+	paths := make([]strings.Builder, len(palette))
+	for y := range d.Grid {
+		for x := 0; x < d.Grid; {
+			i := y*d.Grid + x
+			run := 1
+			for x+run < d.Grid && opaque[i+run] == opaque[i] && index[i+run] == index[i] {
+				run++
+			}
+			// The darkest color is the background, so it needs no path.
+			if opaque[i] && index[i] > 0 {
+				fmt.Fprintf(&paths[index[i]], "M%d %dh%dv1h-%dz", x, y, run, run)
+			}
+			x += run
+		}
+	}
+	var cellPaths strings.Builder
+	for i := 1; i < len(palette); i++ {
+		if paths[i].Len() > 0 {
+			fmt.Fprintf(&cellPaths, `<path d="%s" fill="%s" />`, paths[i].String(), palette[i])
+		}
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 %[2]d %[2]d" shape-rendering="crispEdges"><rect width="100%%" height="100%%" fill="%[3]s" />%[4]s</svg>`,
+		o.Size, d.Grid, palette[0], cellPaths.String())
+}
+
+// hexLuminance is the brightness of a hex or named color, for sorting palettes.
+func hexLuminance(s string) float64 {
+	c, ok := colornames.Map[s]
+	if !ok {
+		h := strings.TrimPrefix(s, "#")
+		if len(h) == 3 {
+			h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+		}
+		v, _ := strconv.ParseUint(h, 16, 32)
+		c = color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}
+	}
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
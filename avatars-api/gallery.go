@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+)
+
+const (
+	galleryDefaultName = "Alex Morgan"
+	sheetColumns       = 4
+	sheetLabelHeight   = 24
+	sheetPadding       = 16
+)
+
+// galleryItem is one style as rendered for the gallery. URL is the
+// generate-avatar request for it, relative to the gallery.
+type galleryItem struct {
+	Type        string       `json:"type"`
+	Description string       `json:"description"`
+	Version     int          `json:"version"`
+	URL         string       `json:"url"`
+	Src         template.URL `json:"src"`
+	svg         string
+}
+
+// galleryControls are the parameters the playground has inputs for. Any
+// other avatar parameter in the URL is passed through as well.
+var galleryControls = []string{"name", "size", "color", "palette", "bg", "shape", "radius", "status", "badge", "ring", "animate"}
+
+// galleryHandler serves /gallery: every registered style drawn for one name,
+// as an HTML playground (default), an SVG contact sheet (format=svg) or JSON
+// (format=json, which the playground's controls fetch on every change).
+func galleryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := strings.ToLower(query.Get("format"))
+	query.Del("format")
+	if query.Get("name") == "" {
+		query.Set("name", galleryDefaultName)
+	}
+
+	items, err := renderGallery(r.Context(), query)
+	switch format {
+	case "", "html":
+		writeGalleryPage(w, query, items, err)
+	case "json":
+		if err != nil {
+			writeAvatarError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	case "svg":
+		if err != nil {
+			writeAvatarError(w, err)
+			return
+		}
+		sheet := contactSheet(query, items)
+		if err := checkSVG(sheet); err != nil {
+			logging.From(r.Context()).Error("unsafe gallery markup", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to render gallery", nil)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+		w.Write([]byte(sheet))
+	default:
+		writeAvatarError(w, badRequest("format", "Invalid format. Use 'html', 'svg' or 'json'."))
+	}
+}
+
+// renderGallery draws every registered style with the shared parameters in
+// query. Parameters a style doesn't take (animate, constellation) are dropped
+// for that style rather than failing the whole gallery, and v is ignored so
+// each style shows its latest version.
+func renderGallery(ctx context.Context, query url.Values) ([]galleryItem, error) {
+	var items []galleryItem
+	for _, style := range registeredStyles() {
+		q := cloneQuery(query)
+		q.Set("type", style.Name)
+		q.Del("v")
+		if !style.Animated {
+			q.Del("animate")
+		}
+		if !contains(style.Options, "constellation") {
+			q.Del("constellation")
+		}
+
+		avatar, err := renderAvatar(ctx, q, "svg")
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, galleryItem{
+			Type:        style.Name,
+			Description: style.Description,
+			Version:     avatar.Version,
+			URL:         "api/generate-avatar?" + q.Encode(),
+			Src:         template.URL(dataURI(avatar.ContentType, avatar.Body)),
+			svg:         string(avatar.Body),
+		})
+	}
+	return items, nil
+}
+
+// contactSheet lays the gallery out as one SVG, sheetColumns wide, with each
+// style's name under its avatar. Avatars are nested <svg>s with their IDs
+// prefixed by the style name, as in a batch sprite.
+func contactSheet(query url.Values, items []galleryItem) string {
+	cell, err := strconv.Atoi(query.Get("size"))
+	if err != nil || cell <= 0 {
+		cell = 100
+	}
+	rows := (len(items) + sheetColumns - 1) / sheetColumns
+	width := sheetColumns*(cell+sheetPadding) + sheetPadding
+	height := rows*(cell+sheetLabelHeight+sheetPadding) + sheetPadding
+	fontSize := 12
+
+	var sheet strings.Builder
+	fmt.Fprintf(&sheet, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[2]d" viewBox="0 0 %[1]d %[2]d">`, width, height)
+	sheet.WriteString(`<rect width="100%" height="100%" fill="#ffffff" />`)
+	for i, item := range items {
+		frame, ok := parseSVGFrame(item.svg)
+		if !ok {
+			continue
+		}
+		x := sheetPadding + (i%sheetColumns)*(cell+sheetPadding)
+		y := sheetPadding + (i/sheetColumns)*(cell+sheetLabelHeight+sheetPadding)
+		fmt.Fprintf(&sheet, `<svg x="%d" y="%d" width="%d" height="%d" viewBox="%g %g %g %g">%s</svg>`,
+			x, y, cell, cell, frame.x, frame.y, frame.w, frame.h, prefixSVGIDs(item.svg[frame.open:frame.end], item.Type))
+		fmt.Fprintf(&sheet, `<text x="%d" y="%d" text-anchor="middle" font-family="Arial, sans-serif" font-size="%d" fill="#495057">%s</text>`,
+			x+cell/2, y+cell+sheetLabelHeight-fontSize/2, fontSize, escapeText(item.Type))
+	}
+	sheet.WriteString(`</svg>`)
+	return sheet.String()
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(galleryHTML))
+
+// galleryPage is the data galleryHTML is rendered with. SheetURL is built in
+// Go, since the template would escape the encoded query a second time inside
+// the href.
+type galleryPage struct {
+	Name     string
+	Values   map[string]string
+	SheetURL template.URL
+	Items    []galleryItem
+	Message  string
+	Errors   []fieldError
+	Shapes   []string
+	Statuses []string
+}
+
+// writeGalleryPage renders the playground. Invalid parameters still get the
+// page, with the errors listed above the controls so they can be fixed there.
+func writeGalleryPage(w http.ResponseWriter, query url.Values, items []galleryItem, err error) {
+	page := galleryPage{
+		Name:     query.Get("name"),
+		Values:   map[string]string{},
+		SheetURL: template.URL("gallery?format=svg&" + query.Encode()),
+		Items:    items,
+		Shapes:   avatarShapes,
+		Statuses: avatarStatuses,
+	}
+	for _, key := range galleryControls {
+		page.Values[key] = query.Get(key)
+	}
+	if items == nil {
+		// Keep a card per style so the controls can fill them in once fixed.
+		for _, style := range registeredStyles() {
+			page.Items = append(page.Items, galleryItem{Type: style.Name, Description: style.Description})
+		}
+	}
+	status := http.StatusOK
+	if err != nil {
+		page.Message = err.Error()
+		if e, ok := err.(*avatarError); ok {
+			status = e.Status
+			page.Errors = e.Fields
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := galleryTemplate.Execute(&buf, page); err != nil {
+		http.Error(w, "Failed to render gallery", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+const galleryHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<title>Avatar Gallery - {{.Name}}</title>
+	<style>
+		:root {
+			--bg-color: #f8f9fa;
+			--text-color: #212529;
+			--accent-color: #007bff;
+			--code-bg: #e9ecef;
+			--card-bg: #ffffff;
+			--border-color: #dee2e6;
+			--shadow: 0 4px 6px rgba(0,0,0,0.1);
+		}
+		@media (prefers-color-scheme: dark) {
+			:root {
+				--bg-color: #0d1117;
+				--text-color: #e6edf3;
+				--accent-color: #58a6ff;
+				--code-bg: #161b22;
+				--card-bg: #161b22;
+				--border-color: #30363d;
+				--shadow: 0 4px 6px rgba(0,0,0,0.4);
+			}
+		}
+		* { margin: 0; padding: 0; box-sizing: border-box; }
+		body {
+			font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif;
+			line-height: 1.6;
+			color: var(--text-color);
+			background: var(--bg-color);
+		}
+		.header {
+			background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+			color: white;
+			padding: 2rem;
+			text-align: center;
+		}
+		.header a { color: white; }
+		.container { max-width: 1200px; margin: 0 auto; padding: 2rem; }
+		.controls {
+			display: grid;
+			grid-template-columns: repeat(auto-fill, minmax(160px, 1fr));
+			gap: 1rem;
+			background: var(--card-bg);
+			padding: 1.5rem;
+			border-radius: 8px;
+			box-shadow: var(--shadow);
+			border: 1px solid var(--border-color);
+			margin-bottom: 2rem;
+		}
+		.controls label { display: flex; flex-direction: column; font-size: 0.85rem; font-weight: 600; }
+		.controls input, .controls select {
+			margin-top: 0.25rem;
+			padding: 0.4rem;
+			border: 1px solid var(--border-color);
+			border-radius: 4px;
+			background: var(--bg-color);
+			color: var(--text-color);
+			font-weight: normal;
+		}
+		.controls input[type=checkbox] { align-self: flex-start; }
+		.controls .actions { display: flex; gap: 1rem; align-items: end; }
+		.controls a, .controls button { color: var(--accent-color); }
+		.errors {
+			background: #f8d7da;
+			color: #842029;
+			padding: 1rem 1.5rem;
+			border-radius: 8px;
+			margin-bottom: 2rem;
+		}
+		.errors[hidden] { display: none; }
+		.errors ul { margin-left: 1.5rem; }
+		.avatar-grid {
+			display: grid;
+			grid-template-columns: repeat(auto-fill, minmax(200px, 1fr));
+			gap: 1.5rem;
+		}
+		.avatar-card {
+			background: var(--card-bg);
+			border: 1px solid var(--border-color);
+			border-radius: 8px;
+			padding: 1rem;
+			text-align: center;
+		}
+		.avatar-card img {
+			width: 150px;
+			height: 150px;
+			object-fit: contain;
+			margin-bottom: 0.5rem;
+		}
+		.avatar-card h4 { color: var(--accent-color); }
+		.avatar-card p { font-size: 0.85rem; min-height: 2.6em; }
+		.avatar-card a { font-size: 0.8rem; color: var(--accent-color); }
+	</style>
+</head>
+<body>
+	<div class="header">
+		<h1>🖼️ Avatar Gallery</h1>
+		<p>Every style for one name. <a href="./">API documentation</a></p>
+	</div>
+
+	<div class="container">
+		<form class="controls" id="controls" method="get">
+			<label>Name <input name="name" value="{{.Values.name}}"></label>
+			<label>Size <input name="size" type="number" min="1" max="4096" placeholder="100" value="{{.Values.size}}"></label>
+			<label>Color <input name="color" placeholder="#ff5733 or teal" value="{{.Values.color}}"></label>
+			<label>Palette <input name="palette" placeholder="#264653,#2a9d8f" value="{{.Values.palette}}"></label>
+			<label>Background <input name="bg" placeholder="#1a1b26" value="{{.Values.bg}}"></label>
+			<label>Shape
+				<select name="shape">
+					<option value="">Style default</option>
+{{- range .Shapes}}
+					<option{{if eq . $.Values.shape}} selected{{end}}>{{.}}</option>
+{{- end}}
+				</select>
+			</label>
+			<label>Radius <input name="radius" type="number" min="0" max="50" value="{{.Values.radius}}"></label>
+			<label>Status
+				<select name="status">
+					<option value="">None</option>
+{{- range .Statuses}}
+					<option{{if eq . $.Values.status}} selected{{end}}>{{.}}</option>
+{{- end}}
+				</select>
+			</label>
+			<label>Badge <input name="badge" placeholder="3" value="{{.Values.badge}}"></label>
+			<label>Ring <input name="ring" placeholder="#22c55e" value="{{.Values.ring}}"></label>
+			<label>Animate <input name="animate" type="checkbox" value="true"{{if eq .Values.animate "true"}} checked{{end}}></label>
+			<div class="actions">
+				<noscript><button type="submit">Apply</button></noscript>
+				<a id="sheet" href="{{.SheetURL}}" download="avatars.svg">Contact sheet (SVG)</a>
+			</div>
+		</form>
+
+		<div class="errors" id="errors"{{if not .Message}} hidden{{end}}>
+			<strong id="error-message">{{.Message}}</strong>
+			<ul id="error-list">
+{{- range .Errors}}
+				<li><code>{{.Param}}</code>: {{.Message}}</li>
+{{- end}}
+			</ul>
+		</div>
+
+		<div class="avatar-grid" id="grid">
+{{- range .Items}}
+			<div class="avatar-card" data-type="{{.Type}}">
+				<img src="{{.Src}}" alt="{{.Type}}">
+				<h4>{{.Type}}</h4>
+				<p>{{.Description}}</p>
+				<a href="{{.URL}}" target="_blank" rel="noopener">Open URL</a>
+			</div>
+{{- end}}
+		</div>
+	</div>
+
+	<script>
+		const form = document.getElementById('controls');
+		const errors = document.getElementById('errors');
+		let timer;
+
+		function params() {
+			const p = new URLSearchParams();
+			for (const [key, value] of new FormData(form)) {
+				if (value !== '') p.set(key, value);
+			}
+			return p;
+		}
+
+		async function refresh() {
+			const p = params();
+			history.replaceState(null, '', '?' + p);
+			document.getElementById('sheet').href = 'gallery?format=svg&' + p;
+			const response = await fetch('gallery?format=json&' + p);
+			const body = await response.json();
+			if (!response.ok) {
+				document.getElementById('error-message').textContent = body.error;
+				const list = document.getElementById('error-list');
+				list.replaceChildren(...(body.errors || []).map(e => {
+					const li = document.createElement('li');
+					li.textContent = e.param + ': ' + e.message;
+					return li;
+				}));
+				errors.hidden = false;
+				return;
+			}
+			errors.hidden = true;
+			for (const item of body) {
+				const card = document.querySelector('.avatar-card[data-type="' + item.type + '"]');
+				if (!card) continue;
+				card.querySelector('img').src = item.src;
+				card.querySelector('a').href = item.url;
+			}
+		}
+
+		form.addEventListener('input', () => {
+			clearTimeout(timer);
+			timer = setTimeout(refresh, 300);
+		});
+		form.addEventListener('submit', event => {
+			event.preventDefault();
+			refresh();
+		});
+	</script>
+</body>
+</html>`
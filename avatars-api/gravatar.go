@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
+	"github.com/go-chi/chi/v5"
+)
+
+const gravatarDefaultSize = 80
+
+var gravatarHashRegex = regexp.MustCompile(`^([0-9a-f]{32}|[0-9a-f]{64})$`)
+
+// gravatarStyles maps Gravatar's d= defaults onto the closest registered style.
+var gravatarStyles = map[string]string{
+	"identicon": "gravatar",
+	"retro":     "pixel",
+	"robohash":  "ascii",
+	"monsterid": "smile",
+	"wavatar":   "beam",
+	"initials":  "avatar",
+}
+
+// gravatarRedirectHosts lists the hosts an image URL in d= may redirect to,
+// from the comma-separated GRAVATAR_REDIRECT_HOSTS. Left empty, as it is by
+// default, image URL defaults are refused: redirecting to any URL a link
+// carries would make /avatar/{hash} an open redirect.
+var gravatarRedirectHosts = map[string]bool{}
+
+func setGravatarRedirectHosts(list string) {
+	for _, host := range strings.Split(list, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			gravatarRedirectHosts[host] = true
+		}
+	}
+}
+
+// gravatarFormats maps the optional file extension of /avatar/{hash} to a
+// format. Gravatar serves JPEG or PNG; without an extension we send PNG.
+var gravatarFormats = map[string]string{
+	"":      "png",
+	".png":  "png",
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".gif":  "gif",
+	".webp": "webp",
+	".svg":  "svg",
+}
+
+// gravatarHandler serves /avatar/{hash} with Gravatar's URL scheme, so a
+// Gravatar base URL can be swapped for this service. There are no uploaded
+// images, so every hash gets its default: s/size is the size, d/default picks
+// the style and r/rating is accepted but has no effect since every generated
+// avatar is rated G. Other avatar parameters (palette, bg, shape, ...) pass
+// through to the style.
+func gravatarHandler(w http.ResponseWriter, r *http.Request) {
+	param := strings.ToLower(chi.URLParam(r, "hash"))
+	ext := path.Ext(param)
+	hash := strings.TrimSuffix(param, ext)
+	format, ok := gravatarFormats[ext]
+	if !ok {
+		writeAvatarError(w, badRequest("hash", "Invalid extension. Use .png, .jpg, .gif, .webp or .svg."))
+		return
+	}
+	if !gravatarHashRegex.MatchString(hash) {
+		writeAvatarError(w, badRequest("hash", "Invalid hash. Use the MD5 or SHA-256 hex digest of the email address."))
+		return
+	}
+
+	query := r.URL.Query()
+	size := gravatarDefaultSize
+	if raw := firstParam(query, "s", "size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			writeAvatarError(w, badRequest("s", "Invalid size. Use an integer number of pixels."))
+			return
+		}
+		// Gravatar accepts 1–2048; clamp instead of failing so existing URLs work.
+		size = max(minRasterSize, min(n, maxRasterSize))
+	}
+
+	def := firstParam(query, "d", "default")
+	switch strings.ToLower(def) {
+	case "404":
+		writeJSONError(w, http.StatusNotFound, "No avatar for this hash.", nil)
+		return
+	case "mp", "mm":
+		writeStaticAvatar(w, r, mysteryPersonSVG(size), size, format)
+		return
+	case "blank":
+		writeStaticAvatar(w, r, fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[1]d" viewBox="0 0 100 100"></svg>`, size), size, format)
+		return
+	}
+	if lower := strings.ToLower(def); strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		u, err := url.Parse(def)
+		if err != nil || u.Host == "" {
+			writeAvatarError(w, badRequest("d", "Invalid default image URL."))
+			return
+		}
+		if !gravatarRedirectHosts[strings.ToLower(u.Hostname())] {
+			writeAvatarError(w, badRequest("d", "Default image URLs on this host are not allowed."))
+			return
+		}
+		http.Redirect(w, r, u.String(), http.StatusFound)
+		return
+	}
+	style := "gravatar"
+	if def != "" {
+		if style, ok = gravatarStyles[strings.ToLower(def)]; !ok {
+			writeAvatarError(w, badRequest("d", "Invalid default. Use 404, mp, identicon, monsterid, wavatar, retro, robohash, initials, blank or an image URL."))
+			return
+		}
+	}
+
+	// d=initials takes the initials from initials= or, like Gravatar, name=.
+	if style == "avatar" && query.Get("initials") == "" {
+		if name := query.Get("name"); name != "" {
+			query.Set("initials", getInitials(name))
+		}
+	}
+	for _, key := range []string{"s", "d", "r", "f", "default", "rating", "forcedefault"} {
+		query.Del(key)
+	}
+	query.Set("name", hash)
+	query.Set("type", style)
+	query.Set("size", strconv.Itoa(size))
+
+	avatar, err := renderAvatar(r.Context(), query, format)
+	if err != nil {
+		writeAvatarError(w, err)
+		return
+	}
+	writeAvatar(w, avatar)
+}
+
+func firstParam(query url.Values, keys ...string) string {
+	for _, key := range keys {
+		if v := query.Get(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// writeStaticAvatar sends one of the fixed d= images, rasterized if needed.
+func writeStaticAvatar(w http.ResponseWriter, r *http.Request, svgContent string, size int, format string) {
+	avatar := &renderedAvatar{Body: []byte(svgContent), ContentType: "image/svg+xml; charset=utf-8", Version: 1}
+	if format != "svg" {
+		_, span := tracing.Start(r.Context(), "rasterize "+format)
+		body, err := rasterizeAvatar(svgContent, size, format)
+		tracing.End(span, err)
+		if err != nil {
+			logging.From(r.Context()).Error("rasterize failed", "style", "gravatar default", "format", format, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to render avatar", nil)
+			return
+		}
+		avatar.Body, avatar.ContentType = body, rasterFormats[format]
+	}
+	writeAvatar(w, avatar)
+}
+
+// mysteryPersonSVG is the d=mp silhouette, the same for every hash.
+func mysteryPersonSVG(size int) string {
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="%[1]d" viewBox="0 0 100 100">
+		<rect width="100%%" height="100%%" fill="#c6cbd1" />
+		<circle cx="50" cy="38" r="18" fill="#ffffff" />
+		<path d="M 14 100 C 14 74 30 62 50 62 C 70 62 86 74 86 100 Z" fill="#ffffff" />
+	</svg>`, size)
+}
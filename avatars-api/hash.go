@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"hash"
+	"hash/fnv"
+)
+
+const maxSeedLength = 256
+
+// hashAlgorithms are the digests a request can pick with `hash`. Generators only
+// read the first 16 bytes, which is the whole digest for md5 and fnv.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+	"fnv":    func() hash.Hash { return fnv.New128a() },
+}
+
+var hashNames = []string{"md5", "sha256", "fnv"}
+
+// digest returns the bytes every generator derives its choices from. The input
+// is the seed when one is given and the name otherwise, plus a per-use suffix.
+// A salt turns the digest into an HMAC keyed by the salt, so avatars can't be
+// matched back to a list of known names or emails. With no options set this is
+// md5(name + suffix), which is what every avatar was generated from originally.
+func (o AvatarOptions) digest(suffix string) [16]byte {
+	newHash, ok := hashAlgorithms[o.Hash]
+	if !ok {
+		newHash = md5.New
+	}
+	input := o.Name
+	if o.Seed != "" {
+		input = o.Seed
+	}
+
+	var h hash.Hash
+	if o.Salt != "" {
+		h = hmac.New(newHash, []byte(o.Salt))
+	} else {
+		h = newHash()
+	}
+	h.Write([]byte(input + suffix))
+
+	var sum [16]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// color is the OKLCH color generated from digest(suffix).
+func (o AvatarOptions) color(suffix string) string {
+	return generateColor(o.digest(suffix))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// zeroWidthNonJoiner keeps Arabic initials in their isolated forms; without it
+// two initials would be shaped as one joined word.
+const zeroWidthNonJoiner = "\u200c"
+
+// getInitials returns the first grapheme cluster of the first two words of a
+// name, so accented letters, Indic conjuncts and emoji sequences stay whole.
+// Leading punctuation such as "(" or "@" is skipped. Names written without
+// spaces, as CJK names usually are, give a single initial.
+func getInitials(name string) string {
+	var initials []string
+	for _, word := range strings.Fields(norm.NFC.String(name)) {
+		if g := firstLetter(word); g != "" {
+			initials = append(initials, strings.ToTitle(g))
+		}
+		if len(initials) == 2 {
+			break
+		}
+	}
+	if len(initials) == 0 {
+		return "?"
+	}
+	sep := ""
+	if isArabic(initials[0]) {
+		sep = zeroWidthNonJoiner
+	}
+	return strings.Join(initials, sep)
+}
+
+// legacyInitials is getInitials as v1 of the lettered styles had it: the
+// first rune of the first two words, upper-cased, whatever those runes are.
+func legacyInitials(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "?"
+	}
+	parts := strings.Fields(name)
+	initials := string([]rune(parts[0])[0])
+	if len(parts) > 1 {
+		initials += string([]rune(parts[1])[0])
+	}
+	return strings.ToUpper(initials)
+}
+
+// legacyGlyph looks a rune up the way v1 did, when the bitmap fonts only had
+// A-Z: anything else is drawn as '?'.
+func legacyGlyph(font map[rune][]string, char string) []string {
+	runes := []rune(char)
+	if len(runes) == 1 && (runes[0] >= 'A' && runes[0] <= 'Z') {
+		return font[runes[0]]
+	}
+	return font['?']
+}
+
+// firstLetter returns the first grapheme cluster of word that starts with a
+// letter, digit or symbol (which covers emoji).
+func firstLetter(word string) string {
+	state := -1
+	for word != "" {
+		var cluster string
+		cluster, word, _, state = uniseg.FirstGraphemeClusterInString(word, state)
+		r := []rune(cluster)[0]
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.So, r) {
+			return cluster
+		}
+	}
+	return ""
+}
+
+func isArabic(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Arabic, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// graphemes splits s into grapheme clusters, dropping joiner controls.
+func graphemes(s string) []string {
+	var clusters []string
+	state := -1
+	for s != "" {
+		var cluster string
+		cluster, s, _, state = uniseg.FirstGraphemeClusterInString(s, state)
+		if cluster != zeroWidthNonJoiner {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
+// glyphAliases maps letters that look the same as a glyph already in the
+// bitmap fonts, mostly Greek and Cyrillic capitals that share Latin shapes.
+var glyphAliases = map[rune]rune{
+	'Α': 'A', 'Β': 'B', 'Γ': 'Г', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+	'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Π': 'П', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Φ': 'Ф', 'Χ': 'X',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'З': '3', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O',
+	'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J',
+	'Ł': 'L', 'Ø': 'O', 'Đ': 'D', 'Ð': 'D', 'Ħ': 'H',
+}
+
+// bitmapGlyph finds the bitmap for a grapheme cluster in one of the fonts.
+// Clusters that aren't in the font directly are tried through glyphAliases
+// and then with their diacritics removed, so "É" is drawn as "E" and "Ё" as
+// "Е". It reports false when the cluster can't be drawn from the font.
+func bitmapGlyph(font map[rune][]string, cluster string) ([]string, bool) {
+	lookup := func(s string) ([]string, bool) {
+		runes := []rune(s)
+		if len(runes) != 1 {
+			return nil, false
+		}
+		r := unicode.ToUpper(runes[0])
+		if alias, ok := glyphAliases[r]; ok {
+			r = alias
+		}
+		grid, ok := font[r]
+		return grid, ok
+	}
+
+	if grid, ok := lookup(norm.NFC.String(cluster)); ok {
+		return grid, true
+	}
+	base := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(cluster))
+	return lookup(base)
+}
+
+// blankGlyph is an unlit bitmap, used under characters drawn by glyphText.
+func blankGlyph(rows int) []string {
+	return slices.Repeat([]string{"00000"}, rows)
+}
+
+// glyphText draws a grapheme the bitmap fonts can't show as a text element
+// centered on the glyph's cell, so every script still renders in browsers.
+// animations are placed inside the element.
+func glyphText(cluster string, cx, cy, size float64, fill, animations string) string {
+	return fmt.Sprintf(`<text x="%g" y="%g" dominant-baseline="central" text-anchor="middle" font-family="monospace" font-weight="bold" font-size="%g" fill="%s">%s%s</text>`,
+		cx, cy, size, fill, animations, html.EscapeString(cluster))
+}
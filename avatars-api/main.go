@@ -1,64 +1,83 @@
 package main
 
 import (
-	"crypto/md5"
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"html"
-	"log"
+	"html/template"
+	"log/slog"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	"github.com/go-chi/httprate"
-	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/cache"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/negotiate"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/server"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 )
 
-var cache *lru.Cache[string, string]
+// avatarCache holds rendered avatars by their full set of parameters. Output
+// is deterministic per version, so the TTL only bounds how long an unpopular
+// avatar occupies a disk or Redis cache.
+var avatarCache *cache.Cache
 
 func main() {
+	r := server.New(server.Defaults("avatars-api", "8000"))
+
 	var err error
-	cache, err = lru.New[string, string](1000)
+	avatarCache, err = cache.FromEnv(cache.Options{Namespace: "avatars", TTL: 24 * time.Hour})
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("❌ Invalid cache settings", "err", err)
+		os.Exit(1)
 	}
-	loadGeoJSON()
-	if len(constellationData.Features) == 0 {
+	metrics.RegisterCache(avatarCache)
+	if len(constellationFeatures()) == 0 {
 		panic("CRITICAL ERROR: No constellation features found in JSON!")
 	}
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Heartbeat("/health"))
-	r.Use(middleware.Compress(5))
-	r.Use(httprate.Limit(
-		100,
-		1*time.Minute,
-		httprate.WithKeyFuncs(httprate.KeyByIP),
-	))
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-	}))
+	r.OnShutdown(avatarCache.Close)
+	setGravatarRedirectHosts(os.Getenv("GRAVATAR_REDIRECT_HOSTS"))
 
 	r.Get("/", documentationHandler)
 	r.Get("/api/generate-avatar", generateAvatarHandler)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
+	r.Get("/api/styles", stylesHandler)
+	r.Get("/api/constellations", constellationsHandler)
+	r.Post("/api/avatars/batch", batchAvatarsHandler)
+	r.Get("/avatar/{hash}", gravatarHandler)
+	r.Get("/gallery", galleryHandler)
+	r.Get("/api/dither", ditherHandler)
+	r.Post("/api/dither", ditherHandler)
+
+	if err := r.Run(); err != nil {
+		slog.Error("❌ Server failed", "err", err)
+		os.Exit(1)
 	}
-	log.Printf("🚀 Server running on port %s", port)
-	http.ListenAndServe(":"+port, r)
 }
+
+var docsTemplate = template.Must(template.New("docs").Parse(apiDocsHTML))
+
 func documentationHandler(w http.ResponseWriter, r *http.Request) {
-	const apiDocsHTML = `
+	var buf bytes.Buffer
+	if err := docsTemplate.Execute(&buf, struct{ Styles []*AvatarStyle }{registeredStyles()}); err != nil {
+		http.Error(w, "Failed to render documentation", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+const apiDocsHTML = `
 <!DOCTYPE html>
 <html lang="en">
 <head>
@@ -272,7 +291,7 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 						<td>String</td>
 						<td><span class="badge optional">Optional</span></td>
 						<td>"User"</td>
-						<td>Name used to generate the avatar. Same name always produces the same avatar.</td>
+						<td>Name used to generate the avatar (up to 256 bytes). Same name always produces the same avatar.</td>
 					</tr>
 					<tr>
 						<td><code>type</code></td>
@@ -286,14 +305,140 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 						<td>Integer</td>
 						<td><span class="badge optional">Optional</span></td>
 						<td>100</td>
-						<td>Size of the avatar in pixels (width and height).</td>
+						<td>Size of the avatar in pixels (width and height), an integer from 1 to 4096 for SVG.</td>
 					</tr>
 					<tr>
 						<td><code>color</code></td>
 						<td>String</td>
 						<td><span class="badge optional">Optional</span></td>
 						<td>Auto</td>
-						<td>Hex color code (e.g., #FF5733). If not provided, color is generated from name.</td>
+						<td>Hex color code (e.g., #FF5733) or SVG color name (e.g., <code>teal</code>) for the style's main color. If not provided, color is generated from name.</td>
+					</tr>
+					<tr>
+						<td><code>palette</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>Auto</td>
+						<td>Comma-separated hex colors (up to 16, <code>#</code> optional) that replace the style's own colors. Each name gets a stable pick from the palette.</td>
+					</tr>
+					<tr>
+						<td><code>bg</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>Style default</td>
+						<td>Hex background color. Styles that are transparent by default get a filled background.</td>
+					</tr>
+					<tr>
+						<td><code>shape</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>Style default</td>
+						<td>Crop the avatar to <code>circle</code>, <code>square</code> or <code>squircle</code>.</td>
+					</tr>
+					<tr>
+						<td><code>radius</code></td>
+						<td>Number</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>0</td>
+						<td>Corner radius for <code>shape=square</code>, as a percentage of the size (0–50). Implies <code>shape=square</code> when no shape is given.</td>
+					</tr>
+					<tr>
+						<td><code>seed</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>name</td>
+						<td>Hashed instead of the name, e.g. a user ID, so the look survives a rename.</td>
+					</tr>
+					<tr>
+						<td><code>salt</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>None</td>
+						<td>Keys the hash (HMAC) so avatars can't be matched back to known names or emails.</td>
+					</tr>
+					<tr>
+						<td><code>hash</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>"md5"</td>
+						<td>Hash the generators draw from: <code>md5</code>, <code>sha256</code> or <code>fnv</code>.</td>
+					</tr>
+					<tr>
+						<td><code>v</code></td>
+						<td>Integer</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>Latest</td>
+						<td>Pins the style's generator version so the avatar never changes. The version used is returned in <code>X-Avatar-Version</code>.</td>
+					</tr>
+					<tr>
+						<td><code>initials</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>From name</td>
+						<td>Up to 3 characters shown instead of the name's initials by the lettered styles (avatar, dotmatrix, terminal, glitch). Characters are grapheme clusters, so accented letters and emoji count as one.</td>
+					</tr>
+					<tr>
+						<td><code>format</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>"svg"</td>
+						<td>Output format: <code>svg</code>, <code>png</code>, <code>webp</code>, <code>jpeg</code>, <code>gif</code>, <code>apng</code> or <code>json</code>. Raster formats use <code>size</code> as the pixel dimension (16–1024, or up to 512 for animated <code>gif</code>/<code>apng</code>). When omitted, the <code>Accept</code> header picks the format.</td>
+					</tr>
+					<tr>
+						<td><code>animate</code></td>
+						<td>Boolean</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>false</td>
+						<td>Animated variant for <code>glitch</code>, <code>marble</code>, <code>constellation</code> and <code>dotmatrix</code>. SVG output uses SMIL; request <code>gif</code> or <code>apng</code> for an animated raster.</td>
+					</tr>
+					<tr>
+						<td><code>constellation</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>From name</td>
+						<td>Figure drawn by <code>constellation</code>, by ID or name (e.g. <code>Teapot</code>). The list is at <code>GET /avatars/api/constellations</code>. Stars are sized by magnitude; <code>v=1</code> draws them all alike.</td>
+					</tr>
+					<tr>
+						<td><code>status</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>None</td>
+						<td>Presence dot in the bottom-right corner: <code>online</code>, <code>away</code>, <code>busy</code> or <code>offline</code>.</td>
+					</tr>
+					<tr>
+						<td><code>badge</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>None</td>
+						<td>Corner badge with a number or up to 4 characters, e.g. <code>3</code> or <code>new</code>. Numbers over 99 show as <code>99+</code>.</td>
+					</tr>
+					<tr>
+						<td><code>ring</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>None</td>
+						<td>Hex or named color of a border around the avatar. Follows <code>shape</code>, and implies <code>shape=circle</code> when no shape is given.</td>
+					</tr>
+					<tr>
+						<td><code>ringwidth</code></td>
+						<td>Number</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>4</td>
+						<td>Ring width as a percentage of the size (up to 20).</td>
+					</tr>
+					<tr>
+						<td><code>group</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>None</td>
+						<td>2–4 comma-separated names drawn together as one avatar, each from its own name. Can't be combined with <code>seed</code> or <code>initials</code>.</td>
+					</tr>
+					<tr>
+						<td><code>layout</code></td>
+						<td>String</td>
+						<td><span class="badge optional">Optional</span></td>
+						<td>"split"</td>
+						<td>How a <code>group</code> is laid out: <code>split</code> into tiles, or <code>overlap</code>ping circles.</td>
 					</tr>
 				</tbody>
 			</table>
@@ -301,122 +446,19 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 
 		<div class="section">
 			<h2>🎭 Avatar Types</h2>
-			<p>All examples use the name "Alex Morgan" for consistency. Each type generates a unique, deterministic design.</p>
+			<p>All examples use the name "Alex Morgan" for consistency. Each type generates a unique, deterministic design. The same list is available as JSON from <code>GET /avatars/api/styles</code>. To compare every style for your own name, with controls for size, color and overlays, open the <a href="/avatars/gallery">gallery</a>.</p>
 			
 			<div class="avatar-grid">
+{{- range .Styles}}
 				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=avatar&size=150" alt="Avatar">
-					<h4>avatar</h4>
-					<p>Classic circular avatar with initials</p>
-					<code>type=avatar</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=gravatar&size=150" alt="Gravatar">
-					<h4>gravatar</h4>
-					<p>GitHub-style identicon</p>
-					<code>type=gravatar</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=dither&size=150" alt="Dither">
-					<h4>dither</h4>
-					<p>Retro dithered plasma effect</p>
-					<code>type=dither</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=ascii&size=150" alt="ASCII">
-					<h4>ascii</h4>
-					<p>Procedural ASCII robot</p>
-					<code>type=ascii</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=dotmatrix&size=150" alt="Dot Matrix">
-					<h4>dotmatrix</h4>
-					<p>LED dot matrix display</p>
-					<code>type=dotmatrix</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=terminal&size=150" alt="Terminal">
-					<h4>terminal</h4>
-					<p>Retro terminal block text</p>
-					<code>type=terminal</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=bauhaus&size=150" alt="Bauhaus">
-					<h4>bauhaus</h4>
-					<p>Geometric Bauhaus design</p>
-					<code>type=bauhaus</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=ring&size=150" alt="Ring">
-					<h4>ring</h4>
-					<p>Gradient ring pattern</p>
-					<code>type=ring</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=beam&size=150" alt="Beam">
-					<h4>beam</h4>
-					<p>Connected network nodes</p>
-					<code>type=beam</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=marble&size=150" alt="Marble">
-					<h4>marble</h4>
-					<p>Marble texture effect</p>
-					<code>type=marble</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=glitch&size=150" alt="Glitch">
-					<h4>glitch</h4>
-					<p>Cyberpunk glitch effect</p>
-					<code>type=glitch</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=sunset&size=150" alt="Sunset">
-					<h4>sunset</h4>
-					<p>Procedural sunset scene</p>
-					<code>type=sunset</code>
+					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type={{.Name}}&size=150" alt="{{.Name}}">
+					<h4>{{.Name}}</h4>
+					<p>{{.Description}}</p>
+					<code>type={{.Name}}</code>
 				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=smile&size=150" alt="Smile">
-					<h4>smile</h4>
-					<p>Minimalist face with expressions</p>
-					<code>type=smile</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=circuit&size=150" alt="Circuit">
-					<h4>circuit</h4>
-					<p>Circuit board pattern</p>
-					<code>type=circuit</code>
-				</div>
-
-				<div class="avatar-card">
-					<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=pixel&size=150" alt="Pixel">
-					<h4>pixel</h4>
-					<p>Isometric pixel art cube</p>
-					<code>type=pixel</code>
-				</div>
-
-			<div class="avatar-card">
-				<img src="/avatars/api/generate-avatar?name=Alex%20Morgan&type=constellation&size=150" alt="Constellation">
-				<h4>constellation</h4>
-				<p>Real constellation star maps</p>
-				<code>type=constellation</code>
+{{- end}}
 			</div>
-	</div>
-</div>
+		</div>
 
 <div class="section">
 	<h2>💡 Usage Examples</h2>
@@ -427,6 +469,20 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 	<h3>With Custom Color</h3>
 	<pre><code>&lt;img src="/avatars/api/generate-avatar?name=John&type=gravatar&color=%23FF5733" alt="Avatar"&gt;</code></pre>
 
+	<h3>Brand Palette</h3>
+	<pre><code>&lt;img src="/avatars/api/generate-avatar?name=Jane&type=bauhaus&palette=%23264653,%232a9d8f,%23e9c46a&shape=squircle" alt="Avatar"&gt;</code></pre>
+
+	<h3>Animated</h3>
+	<pre><code>&lt;img src="/avatars/api/generate-avatar?name=Alex&type=constellation&animate=true" alt="Avatar"&gt;
+&lt;img src="/avatars/api/generate-avatar?name=Alex&type=glitch&animate=true&format=gif&size=128" alt="Avatar"&gt;</code></pre>
+
+	<h3>Specific Constellation</h3>
+	<pre><code>&lt;img src="/avatars/api/generate-avatar?name=Alex&type=constellation&constellation=southern%20cross" alt="Avatar"&gt;</code></pre>
+
+	<h3>Status, Badges and Groups</h3>
+	<pre><code>&lt;img src="/avatars/api/generate-avatar?name=Alex&type=beam&shape=circle&status=online&badge=3" alt="Avatar"&gt;
+&lt;img src="/avatars/api/generate-avatar?type=beam&group=Alice,Bob,Carol&layout=overlap&ring=%2322c55e" alt="Team"&gt;</code></pre>
+
 	<h3>Large Size</h3>
 	<pre><code>&lt;img src="/avatars/api/generate-avatar?name=Sarah&type=dotmatrix&size=500" alt="Avatar"&gt;</code></pre>
 
@@ -436,13 +492,28 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
   .then(svg => {
     document.getElementById('avatar').innerHTML = svg;
   });</code></pre>
+
+	<h3>Batch (Team Pages)</h3>
+	<p>Send up to 250 avatars to <code>POST /avatars/api/avatars/batch</code> as a JSON array of <code>{id, name, type, size}</code>. Shared parameters go in the query string. Use <code>output=sprite</code> (default, one <code>&lt;symbol&gt;</code> per id), <code>output=zip</code> or <code>output=json</code> (data URIs), and <code>format</code> for raster files. Raster batches may render at most 16 megapixels, each frame of an animation included.</p>
+	<pre><code>fetch('/avatars/api/avatars/batch?output=json&format=png', {
+  method: 'POST',
+  body: JSON.stringify([{ id: 'u1', name: 'Alice' }, { id: 'u2', name: 'Bob', type: 'beam' }])
+}).then(response => response.json())</code></pre>
+
+	<h3>Dithered Photos</h3>
+	<p>Dither a real image with <code>/avatars/api/dither</code>: pass a public URL as <code>src</code>, or <code>POST</code> the image (raw or as the <code>image</code> form field, up to 5 MB). Pick <code>algorithm=bayer4</code>, <code>bayer8</code>, <code>floyd-steinberg</code> or <code>atkinson</code>, the number of cells with <code>grid</code> (8–128) and the colors with <code>palette</code>, or <code>bg</code> and <code>color</code>.</p>
+	<pre><code>&lt;img src="/avatars/api/dither?src=https%3A%2F%2Fexample.com%2Fme.jpg&algorithm=atkinson&grid=48&shape=circle" alt="Avatar"&gt;</code></pre>
+
+	<h3>Gravatar-Compatible URLs</h3>
+	<p>Swap <code>https://www.gravatar.com/avatar/</code> for <code>/avatars/avatar/</code>. The hash is the MD5 or SHA-256 of the email; <code>s</code>, <code>d</code> (<code>identicon</code>, <code>retro</code>, <code>robohash</code>, <code>monsterid</code>, <code>wavatar</code>, <code>initials</code>, <code>mp</code>, <code>blank</code>, <code>404</code> or a URL) and <code>r</code> work as on Gravatar.</p>
+	<pre><code>&lt;img src="/avatars/avatar/55502f40dc8b7c769880b10874abc9d0?s=64&d=retro" alt="Avatar"&gt;</code></pre>
 </div>
 <div class="section">
 			<h2>⚡ Features</h2>
 			<ul style="list-style: none; padding: 0;">
 				<li style="padding: 0.5rem 0;">✅ <strong>Deterministic</strong> - Same name always generates the same avatar</li>
 				<li style="padding: 0.5rem 0;">✅ <strong>SVG Format</strong> - Scalable to any size without quality loss</li>
-				<li style="padding: 0.5rem 0;">✅ <strong>16 Unique Styles</strong> - From classic to creative designs</li>
+				<li style="padding: 0.5rem 0;">✅ <strong>{{len .Styles}} Unique Styles</strong> - From classic to creative designs</li>
 				<li style="padding: 0.5rem 0;">✅ <strong>Fast & Lightweight</strong> - Generated on-the-fly, no storage needed</li>
 				<li style="padding: 0.5rem 0;">✅ <strong>CORS Enabled</strong> - Use from any domain</li>
 				<li style="padding: 0.5rem 0;">✅ <strong>Rate Limited</strong> - 100 requests per minute per IP</li>
@@ -452,8 +523,9 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 
 		<div class="section">
 			<h2>📝 Response Format</h2>
-			<p>All avatars are returned as SVG (Scalable Vector Graphics) with the content type:</p>
+			<p>By default avatars are returned as SVG (Scalable Vector Graphics) with the content type:</p>
 			<div class="endpoint">Content-Type: image/svg+xml; charset=utf-8</div>
+			<p>Pass <code>format=png</code>, <code>format=webp</code> or <code>format=jpeg</code> for a rasterized image (<code>image/png</code>, <code>image/webp</code>, <code>image/jpeg</code>) that works in email clients, chat bots and OG previews.</p>
 			
 			<h3>Status Codes</h3>
 			<table>
@@ -470,7 +542,7 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 					</tr>
 					<tr>
 						<td><code>400 Bad Request</code></td>
-						<td>Invalid avatar type specified</td>
+						<td>Invalid parameters. The JSON body lists each one under <code>errors</code> as <code>{"param", "message"}</code></td>
 					</tr>
 					<tr>
 						<td><code>429 Too Many Requests</code></td>
@@ -488,7 +560,7 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 			<h2>🎨 Color Generation</h2>
 			<p>When no color is specified, avatars use the <strong>OKLCH color space</strong> for perceptually uniform, accessible colors. The algorithm:</p>
 			<ul style="margin-left: 2rem; margin-top: 1rem;">
-				<li>Generates a deterministic hash from the input name</li>
+				<li>Generates a deterministic hash from the input name (or <code>seed</code>, keyed by <code>salt</code>)</li>
 				<li>Maps hash values to hue, chroma, and lightness</li>
 				<li>Ensures sufficient contrast for readability</li>
 				<li>Produces consistent colors across all sessions</li>
@@ -501,10 +573,6 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 	</div>
 </body>
 </html>`
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(apiDocsHTML))
-}
 
 func clamp(x float64) float64 {
 	if x < 0 {
@@ -548,8 +616,7 @@ func oklchToHex(l, c, h float64) string {
 	return fmt.Sprintf("#%02x%02x%02x", toByte(red), toByte(green), toByte(blue))
 }
 
-func generateColor(s string) (string, [16]byte) {
-	hash := md5.Sum([]byte(s))
+func generateColor(hash [16]byte) string {
 	hue := float64(uint16(hash[0])<<8|uint16(hash[1])) / 65535.0 * 360.0
 
 	chroma := 0.10 + (float64(hash[2])/255.0)*0.06
@@ -557,22 +624,9 @@ func generateColor(s string) (string, [16]byte) {
 	lightness := 0.55 + (float64(hash[3])/255.0)*0.13
 
 	hex := oklchToHex(lightness, chroma, hue)
-	return hex, hash
-}
-func getInitials(name string) string {
-	name = strings.TrimSpace(name)
-	if name == "" {
-		return "?"
-	}
-	parts := strings.Fields(name)
-	initials := string([]rune(parts[0])[0])
-	if len(parts) > 1 {
-		initials += string([]rune(parts[1])[0])
-	}
-	return strings.ToUpper(initials)
+	return hex
 }
-func generateIdenticon(input string) string {
-	color, hash := generateColor(input)
+func generateIdenticon(hash [16]byte, color string) string {
 	var rects strings.Builder
 	gridSize := 5
 	cellSize := 50
@@ -615,9 +669,11 @@ func getPlasmaValue(x, y int, width, height float64, hash [16]byte) float64 {
 	return (value + 3.0) / 6.0
 }
 
-func generateDitheredAvatar(name string) string {
-	primaryColor, hash := generateColor(name)
-	secondaryColor := "#11011D"
+func generateDitheredAvatar(o AvatarOptions) string {
+	hash := o.digest("")
+	generated := generateColor(hash)
+	primaryColor := o.accent(generated)
+	secondaryColor := o.background("#11011D")
 	cols, rows := 32, 32
 	pixelSize := 10
 
@@ -717,9 +773,11 @@ func pickPart(list []string, b byte) string {
 	return list[int(b)%len(list)]
 }
 
-func generateAsciiRobot(name string, size string) string {
+func generateAsciiRobot(o AvatarOptions) string {
 
-	bgColor, hash := generateColor(name)
+	hash := o.digest("")
+	generated := generateColor(hash)
+	bgColor := o.background(o.accent(generated))
 
 	head := pickPart(robotHeads, hash[0])
 	eyes := pickPart(robotEyes, hash[1])
@@ -754,7 +812,7 @@ func generateAsciiRobot(name string, size string) string {
 				%[4]s
 			</text>
 		</g>
-	</svg>`, size, bgColor, yPos, textBlock.String())
+	</svg>`, o.Size, bgColor, yPos, textBlock.String())
 }
 
 var dotFont = map[rune][]string{
@@ -785,16 +843,54 @@ var dotFont = map[rune][]string{
 	'Y': {"10001", "10001", "10001", "01010", "00100", "00100", "00100"},
 	'Z': {"11111", "00001", "00010", "00100", "01000", "10000", "11111"},
 	'?': {"01110", "10001", "00010", "00100", "00100", "00000", "00100"},
+	// Digits
+	'0': {"01110", "10001", "10011", "10101", "11001", "10001", "01110"},
+	'1': {"00100", "01100", "00100", "00100", "00100", "00100", "01110"},
+	'2': {"01110", "10001", "00001", "00010", "00100", "01000", "11111"},
+	'3': {"11110", "00001", "00001", "01110", "00001", "00001", "11110"},
+	'4': {"00010", "00110", "01010", "10010", "11111", "00010", "00010"},
+	'5': {"11111", "10000", "11110", "00001", "00001", "10001", "01110"},
+	'6': {"00110", "01000", "10000", "11110", "10001", "10001", "01110"},
+	'7': {"11111", "00001", "00010", "00100", "01000", "01000", "01000"},
+	'8': {"01110", "10001", "10001", "01110", "10001", "10001", "01110"},
+	'9': {"01110", "10001", "10001", "01111", "00001", "00010", "01100"},
+	// Cyrillic capitals without a Latin lookalike (see glyphAliases)
+	'Б': {"11111", "10000", "10000", "11110", "10001", "10001", "11110"},
+	'Г': {"11111", "10000", "10000", "10000", "10000", "10000", "10000"},
+	'Д': {"00110", "01010", "01010", "01010", "01010", "11111", "10001"},
+	'Ж': {"10101", "10101", "10101", "01110", "10101", "10101", "10101"},
+	'И': {"10001", "10001", "10011", "10101", "11001", "10001", "10001"},
+	'Й': {"01010", "00100", "10001", "10011", "10101", "11001", "10001"},
+	'Л': {"00111", "01001", "01001", "01001", "01001", "01001", "10001"},
+	'П': {"11111", "10001", "10001", "10001", "10001", "10001", "10001"},
+	'У': {"10001", "10001", "10001", "01111", "00001", "00001", "01110"},
+	'Ф': {"00100", "01110", "10101", "10101", "10101", "01110", "00100"},
+	'Ц': {"10010", "10010", "10010", "10010", "10010", "11111", "00001"},
+	'Ч': {"10001", "10001", "10001", "01111", "00001", "00001", "00001"},
+	'Ш': {"10101", "10101", "10101", "10101", "10101", "10101", "11111"},
+	'Щ': {"10101", "10101", "10101", "10101", "10101", "11111", "00001"},
+	'Ъ': {"11000", "01000", "01000", "01110", "01001", "01001", "01110"},
+	'Ы': {"10001", "10001", "10001", "11101", "10011", "10011", "11101"},
+	'Ь': {"10000", "10000", "10000", "11110", "10001", "10001", "11110"},
+	'Э': {"11110", "00001", "00001", "01111", "00001", "00001", "11110"},
+	'Ю': {"10010", "10101", "10101", "11101", "10101", "10101", "10010"},
+	'Я': {"01111", "10001", "10001", "01111", "00101", "01001", "10001"},
+	// Greek capitals without a Latin or Cyrillic lookalike
+	'Δ': {"00100", "00100", "01010", "01010", "10001", "10001", "11111"},
+	'Θ': {"01110", "10001", "10001", "11111", "10001", "10001", "01110"},
+	'Λ': {"00100", "00100", "01010", "01010", "10001", "10001", "10001"},
+	'Ξ': {"11111", "00000", "00000", "01110", "00000", "00000", "11111"},
+	'Σ': {"11111", "10000", "01000", "00100", "01000", "10000", "11111"},
+	'Ψ': {"10101", "10101", "10101", "01110", "00100", "00100", "00100"},
+	'Ω': {"01110", "10001", "10001", "10001", "01010", "01010", "11011"},
 }
 
-func generateDotMatrix(name string, size string) string {
+func generateDotMatrix(o AvatarOptions) string {
 
-	mainColor, _ := generateColor(name)
+	generated := o.color("")
+	mainColor := o.accent(generated)
 
-	initials := getInitials(name)
-	if len(initials) > 2 {
-		initials = initials[:2]
-	}
+	initials := o.glyphInitials()
 
 	var svgContent strings.Builder
 
@@ -806,40 +902,129 @@ func generateDotMatrix(name string, size string) string {
 
 	currentX := startX
 
-	for _, char := range initials {
-		grid, ok := dotFont[char]
-		if !ok {
-			grid = dotFont['?']
-		}
+	if o.Animate {
+		glyph := func(char string) ([]string, bool) { return o.glyph(dotFont, char) }
+		svgContent.WriteString(dotMatrixMarquee(initials, glyph, mainColor, startX, startY, spacing, dotRadius))
+	} else {
+		for _, char := range initials {
+			grid, ok := o.glyph(dotFont, char)
+			if !ok {
+				grid = blankGlyph(7)
+			}
 
-		for row := range 7 {
-			for col := range 5 {
-				cx := currentX + (col * spacing)
-				cy := startY + (row * spacing)
+			for row := range 7 {
+				for col := range 5 {
+					cx := currentX + (col * spacing)
+					cy := startY + (row * spacing)
 
-				isLit := grid[row][col] == '1'
+					isLit := grid[row][col] == '1'
 
-				if isLit {
+					if isLit {
 
-					fmt.Fprintf(&svgContent, `<circle cx="%d" cy="%d" r="%d" fill="%s" opacity="0.4" />`,
-						cx, cy, dotRadius+2, mainColor)
-					fmt.Fprintf(&svgContent, "<circle cx=\"%d\" cy=\"%d\" r=\"%d\" fill=\"%s\" />\n", cx, cy, dotRadius, mainColor)
-				} else {
-					fmt.Fprintf(&svgContent, `<circle cx="%d" cy="%d" r="%d" fill="#333" opacity="0.3" />`,
-						cx, cy, dotRadius)
+						fmt.Fprintf(&svgContent, `<circle cx="%d" cy="%d" r="%d" fill="%s" opacity="0.4" />`,
+							cx, cy, dotRadius+2, mainColor)
+						fmt.Fprintf(&svgContent, "<circle cx=\"%d\" cy=\"%d\" r=\"%d\" fill=\"%s\" />\n", cx, cy, dotRadius, mainColor)
+					} else {
+						fmt.Fprintf(&svgContent, `<circle cx="%d" cy="%d" r="%d" fill="#333" opacity="0.3" />`,
+							cx, cy, dotRadius)
+					}
 				}
 			}
+			if !ok {
+				svgContent.WriteString(glyphText(char, float64(currentX+2*spacing), float64(startY+3*spacing), float64(6*spacing), mainColor, ""))
+			}
+			currentX += (5 * spacing) + letterSpacing
 		}
-		currentX += (5 * spacing) + letterSpacing
 	}
 
 	return fmt.Sprintf(`
 	<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 170 170">
-		<rect width="100%%" height="100%%" fill="#111111" />
+		<rect width="100%%" height="100%%" fill="%[3]s" />
 		<g transform="translate(8.5, 8.5) scale(0.9)">
 			%[2]s
 		</g>
-	</svg>`, size, svgContent.String())
+	</svg>`, o.Size, svgContent.String(), o.background("#111111"))
+}
+
+// dotMatrixMarquee scrolls the initials through a 12x7 grid of dots, one
+// column per step, with a gap before the text comes round again. Every dot that
+// lights up at some step gets a discrete opacity animation over the loop.
+//
+// Characters missing from dotFont scroll as text: one <text> per position the
+// character can occupy in the window, shown only while its middle column is
+// inside it.
+func dotMatrixMarquee(initials []string, glyph func(string) ([]string, bool), litColor string, startX, startY, spacing, dotRadius int) string {
+	const cols, rows, gap, step = 12, 7, 3, 0.2
+
+	type textGlyph struct {
+		char   string
+		column int
+	}
+	var message [][rows]bool
+	var texts []textGlyph
+	for _, char := range initials {
+		grid, ok := glyph(char)
+		if !ok {
+			grid = blankGlyph(rows)
+			texts = append(texts, textGlyph{char, len(message) + 2})
+		}
+		for col := range 5 {
+			var column [rows]bool
+			for row := range rows {
+				column[row] = grid[row][col] == '1'
+			}
+			message = append(message, column)
+		}
+		message = append(message, [rows]bool{})
+	}
+	for range gap {
+		message = append(message, [rows]bool{})
+	}
+	steps := len(message)
+	dur := float64(steps) * step
+
+	var dots strings.Builder
+	for row := range rows {
+		for col := range cols {
+			cx := startX + col*spacing
+			cy := startY + row*spacing
+			fmt.Fprintf(&dots, `<circle cx="%d" cy="%d" r="%d" fill="#333" opacity="0.3" />`, cx, cy, dotRadius)
+
+			values := make([]string, steps)
+			lit := false
+			for k := range steps {
+				values[k] = "0"
+				if message[(col+k)%steps][row] {
+					values[k] = "1"
+					lit = true
+				}
+			}
+			if !lit {
+				continue
+			}
+			fmt.Fprintf(&dots, `<g opacity="%s">%s<circle cx="%d" cy="%d" r="%d" fill="%s" opacity="0.4" /><circle cx="%d" cy="%d" r="%d" fill="%s" /></g>`,
+				values[0], smilAnimate("opacity", strings.Join(values, ";"), dur, 0, true),
+				cx, cy, dotRadius+2, litColor, cx, cy, dotRadius, litColor)
+		}
+	}
+
+	for _, t := range texts {
+		for offset := 0; offset < cols; offset += steps {
+			xs := make([]string, steps)
+			visible := make([]string, steps)
+			for k := range steps {
+				col := ((t.column-k)%steps+steps)%steps + offset
+				xs[k] = strconv.Itoa(startX + col*spacing)
+				visible[k] = "0"
+				if col < cols {
+					visible[k] = "1"
+				}
+			}
+			dots.WriteString(glyphText(t.char, float64(startX+(t.column+offset)*spacing), float64(startY+3*spacing), float64(6*spacing), litColor,
+				smilAnimate("x", strings.Join(xs, ";"), dur, 0, true)+smilAnimate("opacity", strings.Join(visible, ";"), dur, 0, true)))
+		}
+	}
+	return dots.String()
 }
 
 var blockFont = map[rune][]string{
@@ -870,14 +1055,52 @@ var blockFont = map[rune][]string{
 	'Y': {"10001", "01010", "00100", "00100", "00100"},
 	'Z': {"11111", "00010", "00100", "01000", "11111"},
 	'?': {"01110", "10001", "00100", "00000", "00100"},
+	// Digits
+	'0': {"01110", "10011", "10101", "11001", "01110"},
+	'1': {"00100", "01100", "00100", "00100", "01110"},
+	'2': {"11110", "00001", "01110", "10000", "11111"},
+	'3': {"11110", "00001", "01110", "00001", "11110"},
+	'4': {"10010", "10010", "11111", "00010", "00010"},
+	'5': {"11111", "10000", "11110", "00001", "11110"},
+	'6': {"01110", "10000", "11110", "10001", "01110"},
+	'7': {"11111", "00001", "00010", "00100", "00100"},
+	'8': {"01110", "10001", "01110", "10001", "01110"},
+	'9': {"01110", "10001", "01111", "00001", "01110"},
+	// Cyrillic capitals without a Latin lookalike (see glyphAliases)
+	'Б': {"11111", "10000", "11110", "10001", "11110"},
+	'Г': {"11111", "10000", "10000", "10000", "10000"},
+	'Д': {"01110", "01010", "01010", "11111", "10001"},
+	'Ж': {"10101", "10101", "01110", "10101", "10101"},
+	'И': {"10001", "10011", "10101", "11001", "10001"},
+	'Й': {"00100", "10001", "10011", "10101", "11001"},
+	'Л': {"00111", "01001", "01001", "01001", "10001"},
+	'П': {"11111", "10001", "10001", "10001", "10001"},
+	'У': {"10001", "10001", "01111", "00001", "01110"},
+	'Ф': {"01110", "10101", "10101", "01110", "00100"},
+	'Ц': {"10010", "10010", "10010", "11111", "00001"},
+	'Ч': {"10001", "10001", "01111", "00001", "00001"},
+	'Ш': {"10101", "10101", "10101", "10101", "11111"},
+	'Щ': {"10101", "10101", "10101", "11111", "00001"},
+	'Ъ': {"11000", "01000", "01110", "01001", "01110"},
+	'Ы': {"10001", "10001", "11101", "10011", "11101"},
+	'Ь': {"10000", "10000", "11110", "10001", "11110"},
+	'Э': {"11110", "00001", "01111", "00001", "11110"},
+	'Ю': {"10010", "10101", "11101", "10101", "10010"},
+	'Я': {"01111", "10001", "01111", "01001", "10001"},
+	// Greek capitals without a Latin or Cyrillic lookalike
+	'Δ': {"00100", "01010", "01010", "10001", "11111"},
+	'Θ': {"01110", "10001", "11111", "10001", "01110"},
+	'Λ': {"00100", "01010", "01010", "10001", "10001"},
+	'Ξ': {"11111", "00000", "01110", "00000", "11111"},
+	'Σ': {"11111", "01000", "00100", "01000", "11111"},
+	'Ψ': {"10101", "10101", "01110", "00100", "00100"},
+	'Ω': {"01110", "10001", "10001", "01010", "11011"},
 }
 
-func generateTerminalBlock(name string, size string) string {
-	textColor, _ := generateColor(name)
-	initials := getInitials(name)
-	if len(initials) > 2 {
-		initials = initials[:2]
-	}
+func generateTerminalBlock(o AvatarOptions) string {
+	generated := o.color("")
+	textColor := o.accent(generated)
+	initials := o.glyphInitials()
 
 	var blocks strings.Builder
 
@@ -891,9 +1114,14 @@ func generateTerminalBlock(name string, size string) string {
 	currentX := startX
 
 	for _, char := range initials {
-		grid, ok := blockFont[char]
+		grid, ok := o.glyph(blockFont, char)
 		if !ok {
-			grid = blockFont['?']
+			// No bitmap: draw the character as text with the same drop shadow.
+			cx, cy := float64(currentX+2*(blockSize+gap)+blockSize/2), float64(startY+2*(blockSize+gap)+blockSize/2)
+			size := float64(5 * (blockSize + gap))
+			fmt.Fprintf(&blocks, `<g opacity="0.5">%s</g>`, glyphText(char, cx+4, cy+4, size, "#000", ""))
+			blocks.WriteString(glyphText(char, cx, cy, size, textColor, ""))
+			grid = blankGlyph(5)
 		}
 
 		for row := range 5 {
@@ -926,20 +1154,21 @@ func generateTerminalBlock(name string, size string) string {
 
 	return fmt.Sprintf(`
 	<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 350 350">
-		<rect width="100%%" height="100%%" fill="#1a1b26" />
+		<rect width="100%%" height="100%%" fill="%[4]s" />
 		%[3]s
 		<g transform="translate(17.5, 17.5) scale(0.9)">
 			%[2]s
 		</g>
-	</svg>`, size, blocks.String(), scanlines)
+	</svg>`, o.Size, blocks.String(), scanlines, o.background("#1a1b26"))
 }
 
 var bauhausPalette = []string{"#FFB900", "#E74856", "#0078D7", "#0099BC", "#7A7574", "#FF4343", "#00CC6A", "#8E8CD8"}
 
-func generateBauhaus(name string, size string) string {
-	hash := md5.Sum([]byte(name))
-	bgIndex := int(hash[0]) % len(bauhausPalette)
-	bgColor := bauhausPalette[bgIndex]
+func generateBauhaus(o AvatarOptions) string {
+	hash := o.digest("")
+	palette := o.paletteOr(bauhausPalette)
+	bgIndex := int(hash[0]) % len(palette)
+	bgColor := o.background(palette[bgIndex])
 
 	var shapes strings.Builder
 
@@ -950,7 +1179,7 @@ func generateBauhaus(name string, size string) string {
 		h2 := int(hash[i+5])
 		h3 := int(hash[i+8])
 
-		color := bauhausPalette[(bgIndex+i+1)%len(bauhausPalette)]
+		color := palette[(bgIndex+i+1)%len(palette)]
 
 		shapeType := h1 % 3
 
@@ -986,14 +1215,14 @@ func generateBauhaus(name string, size string) string {
 		<g transform="translate(5, 5) scale(0.9)">
 			%[3]s
 		</g>
-	</svg>`, size, bgColor, shapes.String())
+	</svg>`, o.Size, bgColor, shapes.String())
 }
 
-func generateRing(name string, size string) string {
-	hash := md5.Sum([]byte(name))
-	c1, _ := generateColor(name)
-	c2, _ := generateColor(name + "2")
-	c3, _ := generateColor(name + "3")
+func generateRing(o AvatarOptions) string {
+	hash := o.digest("")
+	c1 := o.accent(o.color(""))
+	c2 := o.schemeColor(1, o.color("2"))
+	c3 := o.schemeColor(2, o.color("3"))
 
 	angle := int(hash[0]) % 360
 
@@ -1008,15 +1237,17 @@ func generateRing(name string, size string) string {
 				<stop offset="100%%" stop-color="%[4]s" />
 			</linearGradient>
 		</defs>
+		%[7]s
 		<g transform="translate(5, 5) scale(0.9)">
 			<circle cx="50" cy="50" r="50" fill="url(#%[5]s)" />
 		</g>
-	</svg>`, size, c1, c2, c3, gradID, angle)
+	</svg>`, o.Size, c1, c2, c3, gradID, angle, o.backdrop())
 }
-func generateBeam(name string, size string) string {
-	hash := md5.Sum([]byte(name))
-	bgColor := "#0a0a0a"
-	accentColor, _ := generateColor(name)
+func generateBeam(o AvatarOptions) string {
+	hash := o.digest("")
+	bgColor := o.background("#0a0a0a")
+	generated := o.color("")
+	accentColor := o.accent(generated)
 
 	var svgContent strings.Builder
 
@@ -1049,13 +1280,23 @@ func generateBeam(name string, size string) string {
 		<g transform="translate(5, 5) scale(0.9)">
 			%[3]s
 		</g>
-	</svg>`, size, bgColor, svgContent.String())
+	</svg>`, o.Size, bgColor, svgContent.String())
 }
 
-func generateMarble(name string, size string) string {
-	hash := md5.Sum([]byte(name))
-	c1, _ := generateColor(name)
-	c2, _ := generateColor(name + "x")
+func generateMarble(o AvatarOptions) string {
+	hash := o.digest("")
+	c1 := o.accent(o.color(""))
+	c2 := o.schemeColor(1, o.color("x"))
+
+	var flow string
+	if o.Animate {
+		// Walk both ends of the gradient axis around the corners, rotating it once per loop.
+		begin := -float64(hash[2]%16) / 4
+		flow = smilAnimate("x1", "0%;100%;100%;0%;0%", 4, begin, false) +
+			smilAnimate("y1", "0%;0%;100%;100%;0%", 4, begin, false) +
+			smilAnimate("x2", "100%;0%;0%;100%;100%", 4, begin, false) +
+			smilAnimate("y2", "100%;100%;0%;0%;100%", 4, begin, false)
+	}
 
 	freq := 0.005 + (float64(hash[0])/255.0)*0.02
 
@@ -1070,23 +1311,24 @@ func generateMarble(name string, size string) string {
 					<feDistantLight azimuth="45" elevation="60" />
 				</feDiffuseLighting>
 			</filter>
-			<linearGradient id="grad" x1="0%%" y1="0%%" x2="100%%" y2="100%%">
+			<linearGradient id="grad" x1="0%%" y1="0%%" x2="100%%" y2="100%%">%[7]s
 				<stop offset="0%%" stop-color="%[2]s" />
 				<stop offset="100%%" stop-color="%[3]s" />
 			</linearGradient>
 		</defs>
+		%[6]s
 		<g transform="translate(5, 5) scale(0.9)">
 			<rect width="100%%" height="100%%" fill="url(#grad)" />
 			<rect width="100%%" height="100%%" fill="transparent" filter="url(#liquid)" opacity="0.5" style="mix-blend-mode: overlay;" />
 		</g>
-	</svg>`, size, c1, c2, freq, octaves)
+	</svg>`, o.Size, c1, c2, freq, octaves, o.backdrop(), flow)
 }
 
-func generateGlitch(name string, size string) string {
-	hash := md5.Sum([]byte(name))
-	initials := getInitials(name)
+func generateGlitch(o AvatarOptions) string {
+	hash := o.digest("")
+	initials := html.EscapeString(o.initials())
 
-	bgColor := "#0f0f0f"
+	bgColor := o.background("#0f0f0f")
 
 	var glitchLines strings.Builder
 	for i := 0; i < 5; i++ {
@@ -1095,35 +1337,55 @@ func generateGlitch(name string, size string) string {
 		w := int(hash[i+10])%50 + 20
 		x := int(hash[i+2]) % 80
 
+		if o.Animate {
+			// Lines jump sideways and flicker in discrete steps, like a bad signal.
+			dx := int(hash[i+3])%20 - 10
+			begin := -float64(hash[i]%8) / 4
+			fmt.Fprintf(&glitchLines, `<rect x="%d" y="%d" width="%d" height="%d" fill="white" opacity="0.1">%s%s</rect>`,
+				x, y, w, h,
+				smilAnimate("x", fmt.Sprintf("%d;%d;%d;%d", x, x+dx, x, x-dx/2), 2, begin, true),
+				smilAnimate("opacity", "0.1;0.35;0.1;0", 2, begin, true))
+			continue
+		}
+
 		glitchLines.WriteString(fmt.Sprintf(
 			`<rect x="%d" y="%d" width="%d" height="%d" fill="white" opacity="0.1" />`,
 			x, y, w, h,
 		))
 	}
 
+	var cyanShift, redShift string
+	if o.Animate {
+		amp := 2 + int(hash[15])%4
+		cyanShift = smilAnimate("x", fmt.Sprintf("48;%d;48;47;48", 48-amp), 1, 0, true)
+		redShift = smilAnimate("x", fmt.Sprintf("52;%d;52;53;52", 52+amp), 1, 0, true)
+		glitchLines.WriteString(`<rect x="0" y="0" width="100" height="3" fill="white" opacity="0.15">` +
+			smilAnimate("y", "-3;100", 2, 0, false) + `</rect>`)
+	}
+
 	return fmt.Sprintf(`
 	<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 100 100">
 		<rect width="100%%" height="100%%" fill="%[2]s" />
 		<g transform="translate(5, 5) scale(0.9)">
-			<text x="48" y="55" dominant-baseline="middle" text-anchor="middle" font-family="Arial Black, sans-serif" font-weight="900" font-size="50" fill="#00ffff" opacity="0.8" style="mix-blend-mode: screen;">
+			<text x="48" y="55" dominant-baseline="middle" text-anchor="middle" font-family="Arial Black, sans-serif" font-weight="900" font-size="50" fill="%[5]s" opacity="0.8" style="mix-blend-mode: screen;">%[8]s
 				%[3]s
 			</text>
 			
-			<text x="52" y="55" dominant-baseline="middle" text-anchor="middle" font-family="Arial Black, sans-serif" font-weight="900" font-size="50" fill="#ff0000" opacity="0.8" style="mix-blend-mode: screen;">
+			<text x="52" y="55" dominant-baseline="middle" text-anchor="middle" font-family="Arial Black, sans-serif" font-weight="900" font-size="50" fill="%[6]s" opacity="0.8" style="mix-blend-mode: screen;">%[9]s
 				%[3]s
 			</text>
 			
-			<text x="50" y="55" dominant-baseline="middle" text-anchor="middle" font-family="Arial Black, sans-serif" font-weight="900" font-size="50" fill="#ffffff">
+			<text x="50" y="55" dominant-baseline="middle" text-anchor="middle" font-family="Arial Black, sans-serif" font-weight="900" font-size="50" fill="%[7]s">
 				%[3]s
 			</text>
 			
 			%[4]s
 		</g>
-	</svg>`, size, bgColor, initials, glitchLines.String())
+	</svg>`, o.Size, bgColor, initials, glitchLines.String(), o.schemeColor(1, "#00ffff"), o.schemeColor(2, "#ff0000"), o.accent("#ffffff"), cyanShift, redShift)
 }
 
-func generateSunset(name string, size string) string {
-	hash := md5.Sum([]byte(name))
+func generateSunset(o AvatarOptions) string {
+	hash := o.digest("")
 
 	var skyTop, skyBot string
 	mood := hash[0] % 3
@@ -1159,6 +1421,13 @@ func generateSunset(name string, size string) string {
 		mountColor = "#4caf50"
 	}
 
+	// The sky takes the first palette colors, so the sun only follows an explicit color.
+	skyTop, skyBot = o.schemeColor(0, skyTop), o.schemeColor(1, skyBot)
+	sunColor, mountColor = o.schemeColor(2, sunColor), o.schemeColor(3, mountColor)
+	if o.Color != "" {
+		sunColor = o.Color
+	}
+
 	return fmt.Sprintf(`
 	<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 100 100">
 		<defs>
@@ -1167,18 +1436,18 @@ func generateSunset(name string, size string) string {
 				<stop offset="100%%" stop-color="%[3]s" />
 			</linearGradient>
 		</defs>
-		<rect width="100%%" height="100%%" fill="url(#sky)" />
+		<rect width="100%%" height="100%%" fill="%s" />
 		<g transform="translate(5, 5) scale(0.9)">
 			<circle cx="%d" cy="%d" r="8" fill="%s" opacity="0.9" />
 			
 			<path d="%s" fill="%s" opacity="0.9" />
 		</g>
-	</svg>`, size, skyTop, skyBot, sunX, sunY, sunColor, mountains.String(), mountColor)
+	</svg>`, o.Size, skyTop, skyBot, o.background("url(#sky)"), sunX, sunY, sunColor, mountains.String(), mountColor)
 }
-func generateSmile(name string, size string) string {
-	hash := md5.Sum([]byte(name))
+func generateSmile(o AvatarOptions) string {
+	hash := o.digest("")
 	skinTones := []string{"#FFDFC4", "#F0C8C9", "#E5B99F", "#8D5524", "#C68642", "#FFDCB1", "#E0AC69", "#B9D2B1", "#A8C8E8"}
-	skinColor := skinTones[int(hash[0])%len(skinTones)]
+	skinColor := o.accent(skinTones[int(hash[0])%len(skinTones)])
 	eyeType := int(hash[1]) % 3
 	mouthType := int(hash[2]) % 4
 	hasBlush := int(hash[3])%2 == 0
@@ -1214,19 +1483,20 @@ func generateSmile(name string, size string) string {
 
 	return fmt.Sprintf(`
 	<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 100 100">
+		%[4]s
 		<g transform="translate(5, 5) scale(0.9)">
 			<circle cx="50" cy="50" r="45" fill="%[2]s" />
 			%[3]s
 		</g>
-	</svg>`, size, skinColor, features.String())
+	</svg>`, o.Size, skinColor, features.String(), o.backdrop())
 }
 
-func generateCircuit(name string, size string) string {
-	hash := md5.Sum([]byte(name))
+func generateCircuit(o AvatarOptions) string {
+	hash := o.digest("")
 
 	boardColors := []string{"#004d40", "#1a237e", "#212121", "#1b5e20"}
-	bgColor := boardColors[int(hash[0])%len(boardColors)]
-	traceColor := "#ffd700"
+	bgColor := o.background(boardColors[int(hash[0])%len(boardColors)])
+	traceColor := o.accent("#ffd700")
 
 	var traces strings.Builder
 
@@ -1249,11 +1519,13 @@ func generateCircuit(name string, size string) string {
 		<g transform="translate(5, 5) scale(0.9)">
 			%[3]s
 		</g>
-	</svg>`, size, bgColor, traces.String())
+	</svg>`, o.Size, bgColor, traces.String())
 }
 
-func generatePixel(name string, size string) string {
-	baseHex, hash := generateColor(name)
+func generatePixel(o AvatarOptions) string {
+	hash := o.digest("")
+	generated := generateColor(hash)
+	baseHex := o.accent(generated)
 	var topPattern strings.Builder
 	if hash[0]%2 == 0 {
 		topPattern.WriteString(`<path d="M 50 30 L 70 40 L 50 50 L 30 40 Z" fill="rgba(255,255,255,0.3)" />`)
@@ -1261,7 +1533,7 @@ func generatePixel(name string, size string) string {
 
 	return fmt.Sprintf(`
 	<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 100 100">
-		<rect width="100%%" height="100%%" fill="#f0f0f0" />
+		<rect width="100%%" height="100%%" fill="%[4]s" />
 		<g transform="translate(5, 5) scale(0.9)">
 			<path d="M 20 35 L 50 50 L 50 80 L 20 65 Z" fill="%[2]s" />
 			
@@ -1273,7 +1545,7 @@ func generatePixel(name string, size string) string {
 			
 			%[3]s
 		</g>
-	</svg>`, size, baseHex, topPattern.String())
+	</svg>`, o.Size, baseHex, topPattern.String(), o.background("#f0f0f0"))
 }
 
 type GeoJSON struct {
@@ -1288,7 +1560,8 @@ type Feature struct {
 }
 
 type Properties struct {
-	Name string `json:"n"`
+	Name    string `json:"n"`
+	Spanish string `json:"es"`
 }
 
 type Geometry struct {
@@ -1353,47 +1626,84 @@ var constellationFile embed.FS
 func loadGeoJSON() {
 	data, err := constellationFile.ReadFile("constellations.json")
 	if err != nil {
-		log.Fatalf("Failed to read embedded json: %v", err)
+		slog.Error("❌ Failed to read embedded constellations", "err", err)
+		os.Exit(1)
 	}
 
 	err = json.Unmarshal(data, &constellationData)
 	if err != nil {
-		log.Fatalf("Failed to parse json: %v", err)
+		slog.Error("❌ Failed to parse constellations", "err", err)
+		os.Exit(1)
 	}
 
-	fmt.Printf("✅ Loaded %d constellations\n", len(constellationData.Features))
+	slog.Info("✅ loaded constellations", "count", len(constellationData.Features))
+}
+
+// generateGeoJSONAvatar draws the constellation picked by the constellation
+// parameter, or one chosen from the name. Star dots are sized by magnitude.
+func generateGeoJSONAvatar(o AvatarOptions) string {
+	return drawConstellation(o, true)
 }
 
-func generateGeoJSONAvatar(name string, size string) string {
-	hash := md5.Sum([]byte(name))
-	idx := int(hash[0]) % len(constellationData.Features)
-	feature := constellationData.Features[idx]
+// drawConstellation is the constellation generator; v1 drew every star the
+// same size, so magnitudes are off for it.
+func drawConstellation(o AvatarOptions, magnitudes bool) string {
+	hash := o.digest("")
+	features := constellationFeatures()
+	feature := &features[int(hash[0])%len(features)]
+	if o.Constellation != "" {
+		if f, ok := findConstellation(o.Constellation); ok {
+			feature = f
+		}
+	}
 	lines := normalizeGeoJSON(feature.Geometry.Coordinates)
+	starR := func(li, pi int) float64 {
+		if !magnitudes {
+			return 1.5
+		}
+		return starRadius(starMagnitude(feature.Geometry.Coordinates[li][pi]))
+	}
 
 	bgStart := "#1e1b4b"
 	bgEnd := "#020617"
+	lineColor := o.accent("#93c5fd")
+	glowColor := o.schemeColor(1, "#38bdf8")
+	labelColor := o.schemeColor(2, "#7dd3fc")
 	var svgContent strings.Builder
 
 	for i := range 40 {
 		x := (int(hash[i%16]) * (i + 3)) % 100
 		y := (int(hash[(i+2)%16]) * (i + 5)) % 100
 		op := float64((int(hash[i%16])%5)+1) / 10.0
+		if o.Animate {
+			// Twinkle on a 2s or 4s cycle, each star at its own phase.
+			dur := float64(2 + 2*(int(hash[(i+7)%16])%2))
+			begin := -float64(hash[(i+3)%16]%16) / 4
+			fmt.Fprintf(&svgContent, `<circle cx="%d" cy="%d" r="0.4" fill="white" opacity="%.1f">%s</circle>`,
+				x, y, op, smilAnimate("opacity", fmt.Sprintf("%.1f;0.05;%.1f", op, op), dur, begin, false))
+			continue
+		}
 		fmt.Fprintf(&svgContent, `<circle cx="%d" cy="%d" r="0.4" fill="white" opacity="%.1f" />`, x, y, op)
 	}
-	for _, line := range lines {
+	for li, line := range lines {
 		for i := 0; i < len(line)-1; i++ {
 			p1 := line[i]
 			p2 := line[i+1]
 
-			fmt.Fprintf(&svgContent, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#93c5fd" stroke-width="0.5" opacity="0.8" />`,
-				p1[0], p1[1], p2[0], p2[1])
+			fmt.Fprintf(&svgContent, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="0.5" opacity="0.8" />`,
+				p1[0], p1[1], p2[0], p2[1], lineColor)
 
-			fmt.Fprintf(&svgContent, `<circle cx="%.1f" cy="%.1f" r="1.5" fill="white" />`, p1[0], p1[1])
-			fmt.Fprintf(&svgContent, `<circle cx="%.1f" cy="%.1f" r="3" fill="#38bdf8" opacity="0.2" />`, p1[0], p1[1])
+			fmt.Fprintf(&svgContent, `<circle cx="%.1f" cy="%.1f" r="%.2g" fill="white" />`, p1[0], p1[1], starR(li, i))
+			if o.Animate {
+				glow := smilAnimate("r", "3;4.5;3", 4, -float64(i%8)/2, false) + smilAnimate("opacity", "0.2;0.45;0.2", 4, -float64(i%8)/2, false)
+				fmt.Fprintf(&svgContent, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s" opacity="0.2">%s</circle>`, p1[0], p1[1], glowColor, glow)
+				continue
+			}
+			fmt.Fprintf(&svgContent, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s" opacity="0.2" />`, p1[0], p1[1], glowColor)
 		}
 
 		lastP := line[len(line)-1]
-		fmt.Fprintf(&svgContent, `<circle cx="%.1f" cy="%.1f" r="1.5" fill="white" />`, lastP[0], lastP[1])
+		fmt.Fprintf(&svgContent, `<circle cx="%.1f" cy="%.1f" r="%.2g" fill="white" />`, lastP[0], lastP[1], starR(li, len(line)-1))
 	}
 
 	return fmt.Sprintf(`
@@ -1404,16 +1714,116 @@ func generateGeoJSONAvatar(name string, size string) string {
 				<stop offset="100%%" stop-color="%[3]s" />
 			</radialGradient>
 		</defs>
-		<rect width="100%%" height="100%%" fill="url(#grad)" />
+		<rect width="100%%" height="100%%" fill="%[6]s" />
 		%[4]s
-		<text x="50" y="90" text-anchor="middle" font-family="Times New Roman" font-weight="bold" font-size="6" fill="#7dd3fc" letter-spacing="0.5">
+		<text x="50" y="90" text-anchor="middle" font-family="Times New Roman" font-weight="bold" font-size="6" fill="%[7]s" letter-spacing="0.5">
 			%[5]s
 		</text>
-	</svg>`, size, bgStart, bgEnd, svgContent.String(), strings.ToUpper(feature.Properties.Name))
+	</svg>`, o.Size, bgStart, bgEnd, svgContent.String(), escapeText(strings.ToUpper(feature.Properties.Name)), o.background("url(#grad)"), labelColor)
+}
+
+// avatarOffers lists the formats generateAvatarHandler can negotiate via the
+// Accept header, in order of preference.
+var avatarOffers = []negotiate.Offer{
+	{Format: "svg", MediaType: "image/svg+xml"},
+	{Format: "png", MediaType: "image/png"},
+	{Format: "webp", MediaType: "image/webp"},
+	{Format: "jpeg", MediaType: "image/jpeg"},
+	{Format: "json", MediaType: "application/json"},
+	{Format: "gif", MediaType: "image/gif"},
+	{Format: "apng", MediaType: "image/apng"},
+}
+
+// avatarJSON is the application/json representation of an avatar.
+type avatarJSON struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	Size    string `json:"size"`
+	Color   string `json:"color"`
+	SVG     string `json:"svg"`
+}
+
+// renderedAvatar is the encoded output of renderAvatar.
+type renderedAvatar struct {
+	Body        []byte
+	ContentType string
+	Version     int
+	CacheHit    bool
+}
+
+// avatarError is a failure with the status code it should be reported as.
+// Fields lists the invalid parameters of a 400.
+type avatarError struct {
+	Status  int
+	Message string
+	Fields  fieldErrors
+}
+
+func (e *avatarError) Error() string { return e.Message }
+
+// badRequest reports a single invalid parameter.
+func badRequest(param, format string, args ...any) error {
+	var errs fieldErrors
+	errs.add(param, format, args...)
+	return errs.err()
+}
+
+// writeAvatarError reports an error from renderAvatar as a JSON errorBody.
+func writeAvatarError(w http.ResponseWriter, err error) {
+	if e, ok := err.(*avatarError); ok {
+		writeJSONError(w, e.Status, e.Message, e.Fields)
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, err.Error(), nil)
 }
 
 func generateAvatarHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
+	format := negotiate.Format(r, avatarOffers)
+	w.Header().Add("Vary", "Accept")
+
+	avatar, err := renderAvatar(r.Context(), r.URL.Query(), format)
+	if err != nil {
+		writeAvatarError(w, err)
+		return
+	}
+	writeAvatar(w, avatar)
+}
+
+// writeAvatar sends a rendered avatar with its version and cache headers.
+func writeAvatar(w http.ResponseWriter, avatar *renderedAvatar) {
+	w.Header().Set("X-Avatar-Version", strconv.Itoa(avatar.Version))
+	if avatar.CacheHit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("Content-Type", avatar.ContentType)
+	// Avatars are images: never sniff them as HTML, and if an SVG is opened
+	// directly, don't let it run scripts or load anything.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.WriteHeader(http.StatusOK)
+	w.Write(avatar.Body)
+}
+
+// cacheKey names a cache entry after the JSON encoding of fields, hashed. Free
+// text such as a seed may contain any separator, so joining the fields would
+// let two different requests share a key.
+func cacheKey(kind string, fields ...any) string {
+	b, _ := json.Marshal(fields)
+	sum := sha256.Sum256(b)
+	return kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// renderAvatar validates the avatar parameters in query, then generates and
+// encodes the avatar in format, going through the response cache. It serves
+// both /api/generate-avatar and every item of a batch.
+func renderAvatar(ctx context.Context, query url.Values, format string) (*renderedAvatar, error) {
+	ctx, span := tracing.Start(ctx, "render avatar")
+	defer span.End()
+
 	avatarType := query.Get("type")
 	size := query.Get("size")
 	color := query.Get("color")
@@ -1427,88 +1837,101 @@ func generateAvatarHandler(w http.ResponseWriter, r *http.Request) {
 	if name == "" {
 		name = "User"
 	}
+	if format == "jpg" {
+		format = "jpeg"
+	}
 
-	if color == "" {
-		color, _ = generateColor(name)
+	var errs fieldErrors
+	if len(name) > maxNameLength {
+		errs.add("name", "Name is too long. Use at most %d bytes.", maxNameLength)
+	}
+	style, ok := lookupStyle(avatarType)
+	if !ok {
+		errs.add("type", "Invalid avatar type. Use one of: %s.", strings.Join(styleOrder, ", "))
+	}
+	if color != "" {
+		if color, ok = parseColor(color); !ok {
+			errs.add("color", "Invalid color. Use a hex color such as #ff5733 or an SVG color name.")
+		}
 	}
 
-	cacheKey := fmt.Sprintf("%s:%s:%s:%s", name, avatarType, size, color)
+	opts, customErrs := parseCustomization(query)
+	errs = append(errs, customErrs...)
 
-	if cache != nil {
-		if cachedSVG, found := cache.Get(cacheKey); found {
-			w.Header().Set("X-Cache", "HIT")
-			w.Header().Set("Cache-Control", "public, max-age=86400")
-			w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(cachedSVG))
-			return
+	contentType := "image/svg+xml; charset=utf-8"
+	if format == "json" {
+		contentType = "application/json"
+	} else if format != "svg" {
+		if contentType, ok = rasterFormats[format]; !ok {
+			errs.add("format", "Invalid format. Use 'svg', 'png', 'webp', 'jpeg', 'gif', 'apng' or 'json'.")
+		}
+	}
+	pixels := parseSize(size, format, opts.Animate, &errs)
+	size = strconv.Itoa(pixels)
+	opts.Name, opts.Size, opts.Color = name, size, color
+
+	var generate func(AvatarOptions) string
+	if style != nil {
+		if opts.Constellation != "" && !contains(style.Options, "constellation") {
+			errs.add("constellation", "Type %s has no constellation parameter. Use type=constellation.", avatarType)
+		}
+		if opts.Animate && !style.Animated {
+			errs.add("animate", "Type %s can't be animated. Animated types: %s.", avatarType, strings.Join(animatedStyles(), ", "))
+		}
+		if generate, ok = style.generator(opts.Version); !ok {
+			errs.add("v", "Unsupported version for type %s. The latest is v=%d.", avatarType, style.Version)
+		}
+		if opts.Version == 0 {
+			opts.Version = style.Version
 		}
 	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	key := cacheKey("avatar", name, avatarType, size, color, format,
+		opts.Palette, opts.Background, opts.Shape, opts.Radius, opts.Initials,
+		opts.Seed, opts.Salt, opts.Hash, opts.Version, opts.Animate, opts.Constellation,
+		opts.Status, opts.Badge, opts.Ring, opts.RingWidth, opts.Group, opts.Layout)
+
+	if cached, found := avatarCache.Get(key); found {
+		return &renderedAvatar{Body: cached, ContentType: contentType, Version: opts.Version, CacheHit: true}, nil
+	}
 
 	var avatarContent string
+	if len(opts.Group) > 0 {
+		avatarContent = composeGroup(generate, opts)
+	} else {
+		avatarContent = generate(opts)
+	}
+	avatarContent = applyOverlays(applyShape(avatarContent, opts), opts)
+	if err := checkSVG(avatarContent); err != nil {
+		logging.From(ctx).Error("unsafe avatar markup", "style", avatarType, "err", err)
+		return nil, &avatarError{Status: http.StatusInternalServerError, Message: "Failed to render avatar"}
+	}
 
-	switch avatarType {
-	case "avatar":
-		initials := getInitials(name)
-		avatarContent = fmt.Sprintf(`
-		<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 100 100">
-			<g transform="translate(5, 5) scale(0.9)">
-				<circle cx="50" cy="50" r="50" fill="%[2]s" />
-				<text x="50" y="55" dominant-baseline="middle" text-anchor="middle" font-family="Arial, sans-serif" font-size="40" fill="#ffffff">%[3]s</text>
-			</g>
-		</svg>`, size, color, initials)
-	case "gravatar":
-		identiconRects := generateIdenticon(name)
-		avatarContent = fmt.Sprintf(`
-		<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 250 250">
-			<rect width="100%%" height="100%%" fill="#11011D" />
-			<g transform="translate(20, 10) scale(0.8)">
-				%[3]s
-			</g>	
-		</svg>`, size, color, identiconRects)
-	case "dither":
-		svgBody := generateDitheredAvatar(name)
-		avatarContent = strings.Replace(svgBody, `width="100%" height="100%"`, fmt.Sprintf(`width="%s" height="%s"`, size, size), 1)
-	case "ascii":
-		avatarContent = generateAsciiRobot(name, size)
-	case "dotmatrix":
-		avatarContent = generateDotMatrix(name, size)
-	case "terminal":
-		avatarContent = generateTerminalBlock(name, size)
-	case "bauhaus":
-		avatarContent = generateBauhaus(name, size)
-	case "ring":
-		avatarContent = generateRing(name, size)
-	case "beam":
-		avatarContent = generateBeam(name, size)
-	case "marble":
-		avatarContent = generateMarble(name, size)
-	case "glitch":
-		avatarContent = generateGlitch(name, size)
-	case "sunset":
-		avatarContent = generateSunset(name, size)
-	case "smile":
-		avatarContent = generateSmile(name, size)
-	case "circuit":
-		avatarContent = generateCircuit(name, size)
-	case "pixel":
-		avatarContent = generatePixel(name, size)
-	case "constellation":
-		loadGeoJSON()
-		avatarContent = generateGeoJSONAvatar(name, size)
+	body := []byte(avatarContent)
+	switch format {
+	case "svg":
+	case "json":
+		var err error
+		body, err = json.Marshal(avatarJSON{Name: name, Type: avatarType, Version: opts.Version, Size: size, Color: opts.accent(opts.color("")), SVG: avatarContent})
+		if err != nil {
+			return nil, &avatarError{Status: http.StatusInternalServerError, Message: "Failed to encode avatar"}
+		}
 	default:
-		http.Error(w, "Invalid avatar type. Use 'avatar' or 'gravatar'.", http.StatusBadRequest)
-		return
+		var err error
+		_, span := tracing.Start(ctx, "rasterize "+format)
+		body, err = rasterizeAvatar(avatarContent, pixels, format)
+		tracing.End(span, err)
+		if err != nil {
+			logging.From(ctx).Error("rasterize failed", "style", avatarType, "format", format, "err", err)
+			return nil, &avatarError{Status: http.StatusInternalServerError, Message: "Failed to render avatar"}
+		}
 	}
 
 	// Store in cache
-	if cache != nil {
-		cache.Add(cacheKey, avatarContent)
-	}
+	avatarCache.Set(key, body)
 
-	w.Header().Set("X-Cache", "MISS")
-	w.Header().Set("Cache-Control", "public, max-age=86400")
-	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(avatarContent))
+	return &renderedAvatar{Body: body, ContentType: contentType, Version: opts.Version}, nil
 }
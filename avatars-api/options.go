@@ -0,0 +1,371 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxPaletteColors     = 16
+	maxInitialsGraphemes = 3
+	maxInitialsBytes     = 64
+	squircleDegree       = 4.0
+)
+
+var (
+	hexColorRegex = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	svgRootRegex  = regexp.MustCompile(`<svg[^>]*>`)
+	viewBoxRegex  = regexp.MustCompile(`viewBox="([^"]+)"`)
+	avatarShapes  = []string{"circle", "square", "squircle"}
+)
+
+// parseCustomization reads the parameters shared by every avatar style: palette,
+// bg, shape, radius and initials for styling, seed, salt, hash and v for
+// determinism, animate, constellation, and the status, badge, ring and group
+// overlays. Name, size and color are filled in
+// by the handler. Every invalid parameter is reported, not just the first.
+func parseCustomization(query url.Values) (AvatarOptions, fieldErrors) {
+	var o AvatarOptions
+	var errs fieldErrors
+
+	if raw := query.Get("palette"); raw != "" {
+		for _, c := range strings.Split(raw, ",") {
+			hex, ok := parseHexColor(c)
+			if !ok {
+				errs.add("palette", "Invalid palette color %q. Use a comma-separated list of hex colors.", strings.TrimSpace(c))
+				o.Palette = nil
+				break
+			}
+			o.Palette = append(o.Palette, hex)
+		}
+		if len(o.Palette) > maxPaletteColors {
+			errs.add("palette", "Palette has too many colors. Use at most %d.", maxPaletteColors)
+		}
+	}
+
+	if raw := query.Get("bg"); raw != "" {
+		hex, ok := parseHexColor(raw)
+		if !ok {
+			errs.add("bg", "Invalid bg %q. Use a hex color such as #1a1b26.", raw)
+		}
+		o.Background = hex
+	}
+
+	o.Shape = strings.ToLower(query.Get("shape"))
+	if o.Shape != "" && !contains(avatarShapes, o.Shape) {
+		errs.add("shape", "Invalid shape. Use one of: %s.", strings.Join(avatarShapes, ", "))
+	}
+
+	if raw := query.Get("radius"); raw != "" {
+		r, err := strconv.ParseFloat(raw, 64)
+		if err != nil || r < 0 || r > 50 {
+			errs.add("radius", "Invalid radius. Use a percentage between 0 and 50.")
+		}
+		o.Radius = r
+		if o.Shape == "" {
+			o.Shape = "square"
+		}
+	}
+
+	o.Initials = norm.NFC.String(strings.TrimSpace(query.Get("initials")))
+	if len(o.Initials) > maxInitialsBytes || len(graphemes(o.Initials)) > maxInitialsGraphemes {
+		errs.add("initials", "Initials are too long. Use at most %d characters.", maxInitialsGraphemes)
+	}
+
+	o.Seed = query.Get("seed")
+	if len(o.Seed) > maxSeedLength {
+		errs.add("seed", "Seed must be at most %d bytes.", maxSeedLength)
+	}
+	o.Salt = query.Get("salt")
+	if len(o.Salt) > maxSeedLength {
+		errs.add("salt", "Salt must be at most %d bytes.", maxSeedLength)
+	}
+
+	o.Hash = strings.ToLower(query.Get("hash"))
+	if o.Hash == "" {
+		o.Hash = "md5"
+	}
+	if !contains(hashNames, o.Hash) {
+		errs.add("hash", "Invalid hash. Use one of: %s.", strings.Join(hashNames, ", "))
+	}
+
+	if raw := query.Get("animate"); raw != "" {
+		animate, err := strconv.ParseBool(raw)
+		if err != nil {
+			errs.add("animate", "Invalid animate. Use true or false.")
+		}
+		o.Animate = animate
+	}
+
+	if raw := strings.TrimSpace(query.Get("constellation")); raw != "" {
+		if f, ok := findConstellation(raw); ok {
+			o.Constellation = f.ID
+		} else {
+			errs.add("constellation", "Unknown constellation %q. See /api/constellations for the available figures.", raw)
+		}
+	}
+
+	o.Status = strings.ToLower(query.Get("status"))
+	if o.Status != "" && !contains(avatarStatuses, o.Status) {
+		errs.add("status", "Invalid status. Use one of: %s.", strings.Join(avatarStatuses, ", "))
+	}
+
+	o.Badge = norm.NFC.String(strings.TrimSpace(query.Get("badge")))
+	if n, err := strconv.Atoi(o.Badge); err == nil && n > 99 {
+		o.Badge = "99+"
+	}
+	if len(o.Badge) > maxBadgeBytes || len(graphemes(o.Badge)) > maxBadgeGraphemes {
+		errs.add("badge", "Badge is too long. Use a number or at most %d characters.", maxBadgeGraphemes)
+	}
+
+	if raw := query.Get("ring"); raw != "" {
+		color, ok := parseColor(raw)
+		if !ok {
+			errs.add("ring", "Invalid ring color. Use a hex color such as #22c55e or an SVG color name.")
+		}
+		o.Ring = color
+		o.RingWidth = defaultRingWidth
+		if o.Shape == "" {
+			o.Shape = "circle"
+		}
+	}
+	if raw := query.Get("ringwidth"); raw != "" {
+		width, err := strconv.ParseFloat(raw, 64)
+		if err != nil || width <= 0 || width > maxRingWidth {
+			errs.add("ringwidth", "Invalid ringwidth. Use a percentage of the size between 0 and %g.", maxRingWidth)
+		}
+		if query.Get("ring") == "" {
+			errs.add("ringwidth", "ringwidth needs a ring color, e.g. ring=%%2322c55e.")
+		}
+		o.RingWidth = width
+	}
+
+	if raw := query.Get("group"); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				o.Group = append(o.Group, name)
+			}
+		}
+		if len(o.Group) < minGroupSize || len(o.Group) > maxGroupSize {
+			errs.add("group", "Invalid group. Use %d to %d comma-separated names.", minGroupSize, maxGroupSize)
+		}
+		for _, name := range o.Group {
+			if len(name) > maxNameLength {
+				errs.add("group", "Group name is too long. Use at most %d bytes per name.", maxNameLength)
+				break
+			}
+		}
+		// Members are drawn from their own names; a shared seed or initials
+		// would make them all look the same.
+		if o.Seed != "" {
+			errs.add("seed", "seed can't be combined with group. Each member is drawn from its name.")
+		}
+		if o.Initials != "" {
+			errs.add("initials", "initials can't be combined with group. Each member is drawn from its name.")
+		}
+	}
+	o.Layout = strings.ToLower(query.Get("layout"))
+	if o.Layout != "" {
+		if !contains(groupLayouts, o.Layout) {
+			errs.add("layout", "Invalid layout. Use one of: %s.", strings.Join(groupLayouts, ", "))
+		} else if len(o.Group) == 0 {
+			errs.add("layout", "layout only applies to a group of names.")
+		}
+	}
+
+	if raw := query.Get("v"); raw != "" {
+		v, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(raw), "v"))
+		if err != nil || v < 1 {
+			errs.add("v", "Invalid version. Use a positive integer such as v=1.")
+		} else {
+			o.Version = v
+		}
+	}
+
+	return o, errs
+}
+
+func parseHexColor(s string) (string, bool) {
+	m := hexColorRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", false
+	}
+	return "#" + strings.ToLower(m[1]), true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// accent is the style's main foreground color: the color parameter when set,
+// otherwise the palette's pick for this name, otherwise the style's own default.
+func (o AvatarOptions) accent(fallback string) string {
+	if o.Color != "" {
+		return o.Color
+	}
+	return o.schemeColor(0, fallback)
+}
+
+// schemeColor returns the i-th color of the avatar's scheme. With a palette the
+// colors are taken in order starting from an offset derived from the name, so
+// the same name always lands on the same colors.
+func (o AvatarOptions) schemeColor(i int, fallback string) string {
+	if len(o.Palette) == 0 {
+		return fallback
+	}
+	hash := o.digest("")
+	return o.Palette[(int(hash[0])+i)%len(o.Palette)]
+}
+
+// paletteOr returns the requested palette, or the style's built-in one.
+func (o AvatarOptions) paletteOr(fallback []string) []string {
+	if len(o.Palette) > 0 {
+		return o.Palette
+	}
+	return fallback
+}
+
+// background returns the bg parameter, or the style's own background.
+func (o AvatarOptions) background(fallback string) string {
+	if o.Background != "" {
+		return o.Background
+	}
+	return fallback
+}
+
+// backdrop is a full-canvas rect for styles that are transparent by default.
+// It is empty unless bg was requested.
+func (o AvatarOptions) backdrop() string {
+	if o.Background == "" {
+		return ""
+	}
+	return fmt.Sprintf(`<rect width="100%%" height="100%%" fill="%s" />`, o.Background)
+}
+
+// initials returns the initials override, or the initials of the name.
+func (o AvatarOptions) initials() string {
+	if o.Initials != "" {
+		return o.Initials
+	}
+	if o.legacyInitials {
+		return legacyInitials(o.Name)
+	}
+	return getInitials(o.Name)
+}
+
+// glyphInitials returns at most two upper-case initials, as grapheme clusters,
+// for the bitmap fonts used by the dotmatrix and terminal styles. Legacy
+// initials are split into runes, as v1 did.
+func (o AvatarOptions) glyphInitials() []string {
+	var initials []string
+	if o.legacyInitials {
+		for _, r := range strings.ToUpper(o.initials()) {
+			initials = append(initials, string(r))
+		}
+	} else {
+		initials = graphemes(strings.ToTitle(o.initials()))
+	}
+	if len(initials) > 2 {
+		initials = initials[:2]
+	}
+	return initials
+}
+
+// glyph finds the bitmap for one of glyphInitials in font, through
+// bitmapGlyph or, for legacy initials, legacyGlyph.
+func (o AvatarOptions) glyph(font map[rune][]string, cluster string) ([]string, bool) {
+	if o.legacyInitials {
+		return legacyGlyph(font, cluster), true
+	}
+	return bitmapGlyph(font, cluster)
+}
+
+// applyShape clips a generated avatar to the requested shape. The clip covers
+// the whole viewBox, so every style is cropped the same way.
+func applyShape(svgContent string, o AvatarOptions) string {
+	if o.Shape == "" {
+		return svgContent
+	}
+	frame, ok := parseSVGFrame(svgContent)
+	if !ok {
+		return svgContent
+	}
+	clip := shapeElement(o.Shape, o.Radius, frame.x, frame.y, frame.w, frame.h, "")
+
+	return svgContent[:frame.open] +
+		`<defs><clipPath id="avatar-shape">` + clip + `</clipPath></defs><g clip-path="url(#avatar-shape)">` +
+		svgContent[frame.open:frame.end] + `</g>` + svgContent[frame.end:]
+}
+
+// svgFrame locates the root element of a generated avatar: open is the end of
+// the root <svg> tag, end the start of the closing </svg>, and x, y, w, h the
+// viewBox, which defaults to 0 0 100 100.
+type svgFrame struct {
+	open, end  int
+	x, y, w, h float64
+}
+
+func parseSVGFrame(svgContent string) (svgFrame, bool) {
+	root := svgRootRegex.FindStringIndex(svgContent)
+	end := strings.LastIndex(svgContent, "</svg>")
+	if root == nil || end < root[1] {
+		return svgFrame{}, false
+	}
+
+	frame := svgFrame{open: root[1], end: end, w: 100, h: 100}
+	if m := viewBoxRegex.FindStringSubmatch(svgContent[root[0]:root[1]]); m != nil {
+		if fields := strings.Fields(strings.ReplaceAll(m[1], ",", " ")); len(fields) == 4 {
+			frame.x, _ = strconv.ParseFloat(fields[0], 64)
+			frame.y, _ = strconv.ParseFloat(fields[1], 64)
+			frame.w, _ = strconv.ParseFloat(fields[2], 64)
+			frame.h, _ = strconv.ParseFloat(fields[3], 64)
+		}
+	}
+	return frame, true
+}
+
+// shapeElement draws shape over the box x, y, w, h. attrs are added to the
+// element, e.g. a stroke for the ring overlay.
+func shapeElement(shape string, radius, x, y, w, h float64, attrs string) string {
+	switch shape {
+	case "circle":
+		return fmt.Sprintf(`<ellipse cx="%g" cy="%g" rx="%g" ry="%g"%s />`, x+w/2, y+h/2, w/2, h/2, attrs)
+	case "square":
+		rx := w * radius / 100
+		ry := h * radius / 100
+		return fmt.Sprintf(`<rect x="%g" y="%g" width="%g" height="%g" rx="%g" ry="%g"%s />`, x, y, w, h, rx, ry, attrs)
+	case "squircle":
+		return fmt.Sprintf(`<path d="%s"%s />`, squirclePath(x+w/2, y+h/2, w/2, h/2), attrs)
+	}
+	return ""
+}
+
+// squirclePath traces a superellipse |x|^n + |y|^n = 1 scaled to the given radii.
+func squirclePath(cx, cy, rx, ry float64) string {
+	const steps = 64
+	var d strings.Builder
+	for i := range steps {
+		t := 2 * math.Pi * float64(i) / steps
+		cos, sin := math.Cos(t), math.Sin(t)
+		px := cx + rx*math.Copysign(math.Pow(math.Abs(cos), 2/squircleDegree), cos)
+		py := cy + ry*math.Copysign(math.Pow(math.Abs(sin), 2/squircleDegree), sin)
+		cmd := "L"
+		if i == 0 {
+			cmd = "M"
+		}
+		fmt.Fprintf(&d, "%s %.2f %.2f ", cmd, px, py)
+	}
+	d.WriteString("Z")
+	return d.String()
+}
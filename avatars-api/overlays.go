@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	minGroupSize      = 2
+	maxGroupSize      = 4
+	maxBadgeGraphemes = 4
+	maxBadgeBytes     = 32
+	defaultRingWidth  = 4.0
+	maxRingWidth      = 20.0
+	// overlayEdge is the outline between an overlay and the avatar under it,
+	// unless bg picks another color.
+	overlayEdge = "#ffffff"
+	badgeColor  = "#ef4444"
+)
+
+var (
+	statusColors = map[string]string{
+		"online":  "#22c55e",
+		"away":    "#f59e0b",
+		"busy":    "#ef4444",
+		"offline": "#9ca3af",
+	}
+	avatarStatuses = []string{"online", "away", "busy", "offline"}
+	groupLayouts   = []string{"split", "overlap"}
+)
+
+// groupCell is the box one member of a group takes in the 100x100 composite.
+type groupCell struct{ x, y, w, h float64 }
+
+// splitCells tile the canvas: halves, a half and two quarters, or quadrants.
+// Members are cropped to their cell around their center.
+var splitCells = map[int][]groupCell{
+	2: {{0, 0, 50, 100}, {50, 0, 50, 100}},
+	3: {{0, 0, 50, 100}, {50, 0, 50, 50}, {50, 50, 50, 50}},
+	4: {{0, 0, 50, 50}, {50, 0, 50, 50}, {0, 50, 50, 50}, {50, 50, 50, 50}},
+}
+
+// overlapCells place members as overlapping circles, later ones on top.
+var overlapCells = map[int][]groupCell{
+	2: {{2, 2, 64, 64}, {34, 34, 64, 64}},
+	3: {{24, 2, 52, 52}, {2, 44, 52, 52}, {46, 44, 52, 52}},
+	4: {{2, 2, 54, 54}, {44, 2, 54, 54}, {2, 44, 54, 54}, {44, 44, 54, 54}},
+}
+
+// edge is the color overlays are outlined with, so they stand apart from the
+// avatar under them.
+func (o AvatarOptions) edge() string {
+	return o.background(overlayEdge)
+}
+
+// composeGroup draws every name of o.Group with generate and lays them out in
+// one 100x100 avatar. Each member is a nested <svg> keeping its own viewBox;
+// their IDs are prefixed so gradients and filters don't collide.
+func composeGroup(generate func(AvatarOptions) string, o AvatarOptions) string {
+	cells := splitCells[len(o.Group)]
+	if o.Layout == "overlap" {
+		cells = overlapCells[len(o.Group)]
+	}
+
+	var body strings.Builder
+	body.WriteString(o.backdrop())
+	for i, name := range o.Group {
+		member := o
+		member.Name, member.Group = name, nil
+		svgContent := generate(member)
+		frame, ok := parseSVGFrame(svgContent)
+		if !ok {
+			continue
+		}
+		prefix := fmt.Sprintf("member-%d", i)
+		inner := prefixSVGIDs(svgContent[frame.open:frame.end], prefix)
+
+		c := cells[i]
+		if o.Layout == "overlap" {
+			fmt.Fprintf(&body, `<circle cx="%g" cy="%g" r="%g" fill="%s" />`, c.x+c.w/2, c.y+c.h/2, c.w/2+1.5, o.edge())
+			inner = fmt.Sprintf(`<defs><clipPath id="%[1]s-clip">%[2]s</clipPath></defs><g clip-path="url(#%[1]s-clip)">%[3]s</g>`,
+				prefix, shapeElement("circle", 0, frame.x, frame.y, frame.w, frame.h, ""), inner)
+		}
+		fmt.Fprintf(&body, `<svg x="%g" y="%g" width="%g" height="%g" viewBox="%g %g %g %g" preserveAspectRatio="xMidYMid slice">%s</svg>`,
+			c.x, c.y, c.w, c.h, frame.x, frame.y, frame.w, frame.h, inner)
+	}
+
+	if o.Layout != "overlap" {
+		edge := o.edge()
+		fmt.Fprintf(&body, `<rect x="49.25" y="0" width="1.5" height="100" fill="%s" />`, edge)
+		switch len(o.Group) {
+		case 3:
+			fmt.Fprintf(&body, `<rect x="50" y="49.25" width="50" height="1.5" fill="%s" />`, edge)
+		case 4:
+			fmt.Fprintf(&body, `<rect x="0" y="49.25" width="100" height="1.5" fill="%s" />`, edge)
+		}
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 100 100">%[2]s</svg>`, o.Size, body.String())
+}
+
+// applyOverlays draws the ring, status dot and badge on top of an avatar, in
+// that order. They go after the shape's clip so they may sit on its edge, and
+// are sized relative to the viewBox so they look the same on every style.
+func applyOverlays(svgContent string, o AvatarOptions) string {
+	if o.Ring == "" && o.Status == "" && o.Badge == "" {
+		return svgContent
+	}
+	frame, ok := parseSVGFrame(svgContent)
+	if !ok {
+		return svgContent
+	}
+	x, y, w, h := frame.x, frame.y, frame.w, frame.h
+
+	var overlays strings.Builder
+	if o.Ring != "" {
+		// Inset by half the stroke so the ring stays inside the canvas.
+		sw := w * o.RingWidth / 100
+		radius := max(0, w*o.Radius/100-sw/2) / (w - sw) * 100
+		stroke := fmt.Sprintf(` fill="none" stroke="%s" stroke-width="%g"`, o.Ring, sw)
+		overlays.WriteString(shapeElement(o.Shape, radius, x+sw/2, y+sw/2, w-sw, h-sw, stroke))
+	}
+
+	if o.Status != "" {
+		cx, cy, r := x+w*0.85, y+h*0.85, w*0.11
+		fmt.Fprintf(&overlays, `<circle cx="%g" cy="%g" r="%g" fill="%s" stroke="%s" stroke-width="%g" />`,
+			cx, cy, r, statusColors[o.Status], o.edge(), w*0.035)
+		switch o.Status {
+		case "busy":
+			fmt.Fprintf(&overlays, `<rect x="%g" y="%g" width="%g" height="%g" rx="%g" fill="#ffffff" />`,
+				cx-r*0.55, cy-r*0.16, r*1.1, r*0.32, r*0.16)
+		case "offline":
+			fmt.Fprintf(&overlays, `<circle cx="%g" cy="%g" r="%g" fill="%s" />`, cx, cy, r*0.45, o.edge())
+		}
+	}
+
+	if o.Badge != "" {
+		bh := h * 0.3
+		fontSize := bh * 0.62
+		bw := max(bh, bh*0.5+float64(len(graphemes(o.Badge)))*fontSize*0.62)
+		sw := h * 0.03
+		bx, by := x+w-bw-sw/2, y+sw/2
+		fmt.Fprintf(&overlays, `<rect x="%g" y="%g" width="%g" height="%g" rx="%g" fill="%s" stroke="%s" stroke-width="%g" />`,
+			bx, by, bw, bh, bh/2, badgeColor, o.edge(), sw)
+		fmt.Fprintf(&overlays, `<text x="%g" y="%g" dominant-baseline="central" text-anchor="middle" font-family="Arial, sans-serif" font-weight="bold" font-size="%g" fill="#ffffff">%s</text>`,
+			bx+bw/2, by+bh/2, fontSize, escapeText(o.Badge))
+	}
+
+	return svgContent[:frame.end] + overlays.String() + svgContent[frame.end:]
+}
@@ -0,0 +1,676 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	minRasterSize = 16
+	maxRasterSize = 1024
+)
+
+// rasterFormats maps the supported `format` values to their response content types.
+var rasterFormats = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"webp": "image/webp",
+	"gif":  "image/gif",
+	"apng": "image/apng",
+}
+
+// rasterizeAvatar renders an avatar SVG to a size x size bitmap and encodes it.
+// gif and apng are animated when the SVG carries SMIL animations; the other
+// formats show the first frame.
+func rasterizeAvatar(svgContent string, size int, format string) ([]byte, error) {
+	if format == "gif" || format == "apng" {
+		return encodeAnimation(svgContent, size, format)
+	}
+	if strings.Contains(svgContent, "<animate") {
+		svgContent = sampleAnimations(svgContent, 0)
+	}
+
+	img, err := renderAvatarImage(svgContent, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		// JPEG has no alpha channel, so flatten transparent corners onto white.
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, image.Point{}, draw.Over)
+		err = jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 90})
+	case "webp":
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderAvatarImage rasterizes a static avatar SVG. oksvg covers shapes and
+// gradients; text is drawn separately with the Go fonts because oksvg ignores
+// <text> elements, and clip paths are applied afterwards as an alpha mask.
+func renderAvatarImage(svgContent string, size int) (*image.RGBA, error) {
+	if doc, ok := splitRasterLayers(svgContent); ok {
+		return renderRasterLayers(doc, size)
+	}
+	shapes, texts, clip, viewBoxW := prepareRasterSVG(svgContent)
+
+	icon, err := oksvg.ReadIconStream(strings.NewReader(shapes))
+	if err != nil {
+		return nil, fmt.Errorf("parse svg: %w", err)
+	}
+	if viewBoxW == 0 {
+		viewBoxW = icon.ViewBox.W
+	}
+	img := drawIcon(icon, size)
+
+	scale := 1.0
+	if viewBoxW > 0 {
+		scale = float64(size) / viewBoxW
+	}
+	for _, t := range texts {
+		drawRasterText(img, t, scale)
+	}
+
+	if clip != "" {
+		mask, err := renderSVG(clip, size)
+		if err != nil {
+			return nil, fmt.Errorf("parse clip path: %w", err)
+		}
+		clipped := image.NewRGBA(img.Bounds())
+		draw.DrawMask(clipped, clipped.Bounds(), img, image.Point{}, mask, image.Point{}, draw.Src)
+		img = clipped
+	}
+	return img, nil
+}
+
+// layeredSVG is an avatar split into parts that are rasterized one at a time
+// and composited in order: the output of composeGroup, or an avatar with
+// overlays drawn outside its shape's clip.
+type layeredSVG struct {
+	root    string // the root <svg> start tag
+	viewBox string
+	defs    string            // top-level <defs>, without clip paths
+	clips   map[string]string // clip path ID -> contents
+	layers  []rasterLayer
+}
+
+// rasterLayer is a run of top-level elements. clip names the clip path of a
+// clipped <g>, whose children are the content; nested holds the start tag of
+// a nested <svg>, whose inner markup is the content.
+type rasterLayer struct {
+	content string
+	clip    string
+	nested  map[string]string
+}
+
+var (
+	clipPathRegex = regexp.MustCompile(`(?s)<clipPath id="([^"]+)"[^>]*>(.*?)</clipPath>`)
+	clipURLRegex  = regexp.MustCompile(`^url\(#([^)]+)\)$`)
+)
+
+// splitRasterLayers splits an SVG into layers at its top-level clipped groups
+// and nested <svg> elements. It reports false for documents the single-pass
+// renderer handles: one layer with no nested <svg> in it.
+func splitRasterLayers(svgContent string) (layeredSVG, bool) {
+	if strings.Count(svgContent, "<svg") < 2 && strings.Count(svgContent, "clip-path=") < 1 {
+		return layeredSVG{}, false
+	}
+	doc := layeredSVG{clips: map[string]string{}}
+	var plain strings.Builder
+	flush := func() {
+		if strings.TrimSpace(plain.String()) != "" {
+			doc.layers = append(doc.layers, rasterLayer{content: plain.String()})
+		}
+		plain.Reset()
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(svgContent))
+	depth := 0
+	var childStart, innerStart int64
+	var child xml.StartElement
+	for {
+		start := decoder.InputOffset()
+		tok, err := decoder.RawToken()
+		if err != nil {
+			break
+		}
+		end := decoder.InputOffset()
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 1:
+				doc.root = svgContent[start:end]
+				doc.viewBox = attrMap(t.Attr)["viewBox"]
+			case 2:
+				childStart, innerStart, child = start, end, t.Copy()
+			}
+		case xml.EndElement:
+			depth--
+			if depth != 1 {
+				continue
+			}
+			attrs := attrMap(child.Attr)
+			switch m := clipURLRegex.FindStringSubmatch(attrs["clip-path"]); {
+			case child.Name.Local == "defs":
+				markup := svgContent[childStart:end]
+				for _, c := range clipPathRegex.FindAllStringSubmatch(markup, -1) {
+					doc.clips[c[1]] = c[2]
+				}
+				doc.defs += clipPathRegex.ReplaceAllString(markup, "")
+			case child.Name.Local == "svg":
+				flush()
+				doc.layers = append(doc.layers, rasterLayer{content: svgContent[innerStart:start], nested: attrs})
+			case child.Name.Local == "g" && m != nil:
+				flush()
+				doc.layers = append(doc.layers, rasterLayer{content: svgContent[innerStart:start], clip: m[1]})
+			default:
+				plain.WriteString(svgContent[childStart:end])
+			}
+		default:
+			if depth == 1 {
+				plain.WriteString(svgContent[start:end])
+			}
+		}
+	}
+	flush()
+
+	// A lone plain layer would render as the same document again, and a lone
+	// clipped layer without nested <svg>s is what prepareRasterSVG handles.
+	if len(doc.layers) == 1 && doc.layers[0].nested == nil &&
+		(doc.layers[0].clip == "" || !strings.Contains(doc.layers[0].content, "<svg")) {
+		return layeredSVG{}, false
+	}
+	return doc, doc.root != ""
+}
+
+// renderRasterLayers draws the layers of doc over each other. Clipped layers
+// are masked by their clip path; nested <svg>s are rendered on their own and
+// placed in their viewport.
+func renderRasterLayers(doc layeredSVG, size int) (*image.RGBA, error) {
+	canvas := image.NewRGBA(image.Rect(0, 0, size, size))
+	vb := parseViewBox(doc.viewBox, float64(size))
+	scale := float64(size) / vb[2]
+
+	for _, layer := range doc.layers {
+		if layer.nested != nil {
+			if err := drawNestedSVG(canvas, layer, vb, scale); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		img, err := renderAvatarImage(doc.root+doc.defs+layer.content+"</svg>", size)
+		if err != nil {
+			return nil, err
+		}
+		var mask image.Image = image.Opaque
+		if layer.clip != "" {
+			clipSVG := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s">%s</svg>`, xmlAttrEscape(doc.viewBox), doc.clips[layer.clip])
+			if mask, err = renderSVG(clipSVG, size); err != nil {
+				return nil, fmt.Errorf("parse clip path: %w", err)
+			}
+		}
+		draw.DrawMask(canvas, canvas.Bounds(), img, image.Point{}, mask, image.Point{}, draw.Over)
+	}
+	return canvas, nil
+}
+
+// drawNestedSVG renders a nested <svg> as a document of its own and draws it
+// into its x, y, width, height viewport, scaled to fit (or to cover with
+// preserveAspectRatio="... slice") around the center, and clipped to it.
+// Avatar viewBoxes are square, so the render is too.
+func drawNestedSVG(canvas *image.RGBA, layer rasterLayer, parent [4]float64, scale float64) error {
+	attrs := layer.nested
+	length := func(key string, dim float64) float64 {
+		v := attrs[key]
+		if pct, ok := strings.CutSuffix(v, "%"); ok {
+			f, _ := strconv.ParseFloat(pct, 64)
+			return dim * f / 100
+		}
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	x, y := length("x", parent[2]), length("y", parent[3])
+	w, h := length("width", parent[2]), length("height", parent[3])
+	vb := parseViewBox(attrs["viewBox"], w)
+
+	fit := min(w/vb[2], h/vb[3])
+	if strings.HasSuffix(attrs["preserveAspectRatio"], "slice") {
+		fit = max(w/vb[2], h/vb[3])
+	}
+	px := int(math.Round(vb[2] * fit * scale))
+	if px < 1 {
+		return nil
+	}
+
+	viewBox := attrs["viewBox"]
+	if viewBox == "" {
+		viewBox = fmt.Sprintf("0 0 %g %g", w, h)
+	}
+	img, err := renderAvatarImage(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s">%s</svg>`, xmlAttrEscape(viewBox), layer.content), px)
+	if err != nil {
+		return err
+	}
+
+	cell := image.Rect(
+		int(math.Round((x-parent[0])*scale)), int(math.Round((y-parent[1])*scale)),
+		int(math.Round((x-parent[0]+w)*scale)), int(math.Round((y-parent[1]+h)*scale)),
+	)
+	origin := image.Pt(
+		int(math.Round((x-parent[0]+w/2)*scale))-px/2,
+		int(math.Round((y-parent[1]+h/2)*scale))-px/2,
+	)
+	draw.Draw(canvas, cell, img, cell.Min.Sub(origin), draw.Over)
+	return nil
+}
+
+// parseViewBox reads a viewBox attribute, defaulting to a square of side dim.
+func parseViewBox(viewBox string, dim float64) [4]float64 {
+	vb := [4]float64{0, 0, dim, dim}
+	if fields := strings.Fields(strings.ReplaceAll(viewBox, ",", " ")); len(fields) == 4 {
+		for i, f := range fields {
+			vb[i], _ = strconv.ParseFloat(f, 64)
+		}
+	}
+	if vb[2] <= 0 || vb[3] <= 0 {
+		vb[2], vb[3] = dim, dim
+	}
+	return vb
+}
+
+// renderSVG rasterizes an SVG document that oksvg can read as is.
+func renderSVG(svgContent string, size int) (*image.RGBA, error) {
+	icon, err := oksvg.ReadIconStream(strings.NewReader(svgContent))
+	if err != nil {
+		return nil, err
+	}
+	return drawIcon(icon, size), nil
+}
+
+func drawIcon(icon *oksvg.SvgIcon, size int) *image.RGBA {
+	icon.SetTarget(0, 0, float64(size), float64(size))
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	scanner := rasterx.NewScannerGV(size, size, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(size, size, scanner), 1.0)
+	return img
+}
+
+// rasterText is a single run of text positioned in viewBox coordinates.
+type rasterText struct {
+	X, Y     float64
+	Content  string
+	FontSize float64
+	Fill     string
+	Opacity  float64
+	Anchor   string
+	Baseline string
+	Mono     bool
+	Bold     bool
+}
+
+// textState carries the inherited presentation attributes of a <text>/<tspan>.
+type textState struct {
+	x, y     float64
+	fontSize float64
+	fill     string
+	opacity  float64
+	anchor   string
+	baseline string
+	mono     bool
+	bold     bool
+}
+
+// affine is a translate+scale transform, which is all the generators use on groups.
+type affine struct{ tx, ty, s float64 }
+
+func (a affine) apply(x, y float64) (float64, float64) { return a.tx + x*a.s, a.ty + y*a.s }
+
+var (
+	rgbaFill       = regexp.MustCompile(`^rgba\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*,\s*([\d.]+)\s*\)$`)
+	translateRegex = regexp.MustCompile(`translate\(\s*([-\d.]+)[\s,]+([-\d.]+)\s*\)`)
+	scaleRegex     = regexp.MustCompile(`scale\(\s*([-\d.]+)\s*\)`)
+	patternIDRegex = regexp.MustCompile(`<pattern[^>]*id="([^"]+)"`)
+)
+
+// prepareRasterSVG rewrites an avatar SVG into the subset oksvg understands and
+// pulls the text runs out so they can be drawn on top of the rasterized shapes.
+// Percent lengths are resolved against the viewBox, rgba() fills are split into
+// rgb + fill-opacity, uniform scale() gets both factors, and elements filled
+// from <pattern>s are dropped. The contents of a <clipPath> come back as a
+// separate SVG document; it is applied to the whole canvas, which is how
+// applyShape uses it.
+func prepareRasterSVG(svgContent string) (string, []rasterText, string, float64) {
+	var out, clip strings.Builder
+	var texts []rasterText
+	var viewBox string
+	var viewBoxW, viewBoxH float64
+
+	patterns := map[string]bool{}
+	for _, m := range patternIDRegex.FindAllStringSubmatch(svgContent, -1) {
+		patterns[m[1]] = true
+	}
+
+	transforms := []affine{{s: 1}}
+	var textStack []textState
+	skipDepth, clipDepth := 0, 0
+
+	decoder := xml.NewDecoder(strings.NewReader(svgContent))
+	for {
+		tok, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			break
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			attrs := attrMap(t.Attr)
+			name := t.Name.Local
+
+			parent := transforms[len(transforms)-1]
+			current := parent
+			if tr, ok := attrs["transform"]; ok {
+				current = composeTransform(parent, tr)
+			}
+			transforms = append(transforms, current)
+
+			if name == "svg" {
+				viewBox = attrs["viewBox"]
+				if fields := strings.Fields(strings.ReplaceAll(viewBox, ",", " ")); len(fields) == 4 {
+					viewBoxW, _ = strconv.ParseFloat(fields[2], 64)
+					viewBoxH, _ = strconv.ParseFloat(fields[3], 64)
+				}
+			}
+
+			if name == "text" || name == "tspan" {
+				state := textState{fontSize: 16, fill: "black", opacity: 1}
+				if len(textStack) > 0 {
+					state = textStack[len(textStack)-1]
+				}
+				state = applyTextAttrs(state, attrs, name, viewBoxW, current)
+				textStack = append(textStack, state)
+				continue
+			}
+
+			if fill := attrs["fill"]; strings.HasPrefix(fill, "url(#") {
+				if patterns[strings.TrimSuffix(strings.TrimPrefix(fill, "url(#"), ")")] {
+					skipDepth = 1
+					transforms = transforms[:len(transforms)-1]
+					continue
+				}
+			}
+
+			dst := &out
+			if name == "clipPath" || clipDepth > 0 {
+				clipDepth++
+				if name == "clipPath" {
+					continue
+				}
+				dst = &clip
+			}
+
+			dst.WriteString("<" + name)
+			for _, a := range t.Attr {
+				key, value := a.Name.Local, a.Value
+				if a.Name.Space != "" {
+					key = a.Name.Space + ":" + key
+				}
+				switch {
+				case key == "style" || key == "clip-path":
+					continue
+				case key == "transform":
+					// oksvg leaves the y factor at zero for one-argument scale().
+					value = scaleRegex.ReplaceAllString(value, "scale($1 $1)")
+				case name != "svg" && (key == "width" || key == "height") && strings.HasSuffix(value, "%"):
+					pct, _ := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+					dim := viewBoxW
+					if key == "height" {
+						dim = viewBoxH
+					}
+					value = strconv.FormatFloat(dim*pct/100, 'f', -1, 64)
+				case (key == "fill" || key == "stroke") && value == "transparent":
+					value = "none"
+				case key == "fill" && rgbaFill.MatchString(value):
+					m := rgbaFill.FindStringSubmatch(value)
+					fmt.Fprintf(dst, ` fill="rgb(%s,%s,%s)" fill-opacity="%s"`, m[1], m[2], m[3], m[4])
+					continue
+				}
+				fmt.Fprintf(dst, ` %s="%s"`, key, xmlAttrEscape(value))
+			}
+			dst.WriteString(">")
+
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			transforms = transforms[:len(transforms)-1]
+			if clipDepth > 0 {
+				if clipDepth--; clipDepth > 0 {
+					clip.WriteString("</" + t.Name.Local + ">")
+				}
+				continue
+			}
+			if t.Name.Local == "text" || t.Name.Local == "tspan" {
+				// A tspan's dy offsets accumulate, so hand its pen position back to the parent.
+				if t.Name.Local == "tspan" && len(textStack) > 1 {
+					done := textStack[len(textStack)-1]
+					textStack[len(textStack)-2].x = done.x
+					textStack[len(textStack)-2].y = done.y
+				}
+				textStack = textStack[:len(textStack)-1]
+				continue
+			}
+			out.WriteString("</" + t.Name.Local + ">")
+
+		case xml.CharData:
+			if skipDepth > 0 || len(textStack) == 0 {
+				continue
+			}
+			content := strings.TrimSpace(string(t))
+			if content == "" {
+				continue
+			}
+			s := textStack[len(textStack)-1]
+			texts = append(texts, rasterText{
+				X: s.x, Y: s.y, Content: content, FontSize: s.fontSize, Fill: s.fill,
+				Opacity: s.opacity, Anchor: s.anchor, Baseline: s.baseline, Mono: s.mono, Bold: s.bold,
+			})
+		}
+	}
+
+	clipSVG := ""
+	if clip.Len() > 0 {
+		clipSVG = fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s">%s</svg>`, xmlAttrEscape(viewBox), clip.String())
+	}
+	return out.String(), texts, clipSVG, viewBoxW
+}
+
+func attrMap(attrs []xml.Attr) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		m[a.Name.Local] = a.Value
+	}
+	return m
+}
+
+func xmlAttrEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func composeTransform(parent affine, transform string) affine {
+	local := affine{s: 1}
+	if m := translateRegex.FindStringSubmatch(transform); m != nil {
+		local.tx, _ = strconv.ParseFloat(m[1], 64)
+		local.ty, _ = strconv.ParseFloat(m[2], 64)
+	}
+	if m := scaleRegex.FindStringSubmatch(transform); m != nil {
+		local.s, _ = strconv.ParseFloat(m[1], 64)
+	}
+	return affine{
+		tx: parent.tx + local.tx*parent.s,
+		ty: parent.ty + local.ty*parent.s,
+		s:  parent.s * local.s,
+	}
+}
+
+// applyTextAttrs resolves the attributes of a <text> or <tspan> on top of the
+// inherited state. Positions are returned in root viewBox units.
+func applyTextAttrs(state textState, attrs map[string]string, name string, viewBoxW float64, tr affine) textState {
+	parseLength := func(v string) float64 {
+		if strings.HasSuffix(v, "%") {
+			pct, _ := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+			return viewBoxW * pct / 100
+		}
+		f, _ := strconv.ParseFloat(strings.TrimSuffix(v, "px"), 64)
+		return f
+	}
+
+	if v, ok := attrs["font-size"]; ok {
+		state.fontSize = parseLength(v) * tr.s
+	} else if name == "text" {
+		state.fontSize *= tr.s
+	}
+	if v, ok := attrs["x"]; ok {
+		state.x, _ = tr.apply(parseLength(v), 0)
+	}
+	if v, ok := attrs["y"]; ok {
+		_, state.y = tr.apply(0, parseLength(v))
+	}
+	if v, ok := attrs["dy"]; ok {
+		state.y += parseLength(v) * tr.s
+	}
+	if v, ok := attrs["fill"]; ok {
+		state.fill = v
+	}
+	if v, ok := attrs["opacity"]; ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			state.opacity *= f
+		}
+	}
+	if v, ok := attrs["text-anchor"]; ok {
+		state.anchor = v
+	}
+	if v, ok := attrs["dominant-baseline"]; ok {
+		state.baseline = v
+	}
+	if v, ok := attrs["font-family"]; ok {
+		state.mono = strings.Contains(strings.ToLower(v), "mono")
+	}
+	if v, ok := attrs["font-weight"]; ok {
+		state.bold = v == "bold" || v == "900" || v == "800" || v == "700"
+	}
+	return state
+}
+
+var (
+	fontsOnce sync.Once
+	fontFaces map[string]*opentype.Font
+)
+
+func loadRasterFonts() {
+	fontFaces = map[string]*opentype.Font{}
+	for name, data := range map[string][]byte{
+		"regular": goregular.TTF,
+		"bold":    gobold.TTF,
+		"mono":    gomonobold.TTF,
+	} {
+		f, err := opentype.Parse(data)
+		if err != nil {
+			continue
+		}
+		fontFaces[name] = f
+	}
+}
+
+func drawRasterText(img *image.RGBA, t rasterText, scale float64) {
+	fontsOnce.Do(loadRasterFonts)
+
+	key := "regular"
+	if t.Mono {
+		key = "mono"
+	} else if t.Bold {
+		key = "bold"
+	}
+	f, ok := fontFaces[key]
+	if !ok {
+		return
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: t.FontSize * scale, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return
+	}
+	defer face.Close()
+
+	fill, err := oksvg.ParseSVGColor(t.Fill)
+	if err != nil || fill == nil {
+		return
+	}
+	r, g, b, _ := fill.RGBA()
+	alpha := t.Opacity
+	textColor := color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(255 * alpha)}
+
+	x := t.X * scale
+	y := t.Y * scale
+	width := float64(font.MeasureString(face, t.Content)) / 64
+	switch t.Anchor {
+	case "middle":
+		x -= width / 2
+	case "end":
+		x -= width
+	}
+	if t.Baseline == "middle" || t.Baseline == "central" {
+		metrics := face.Metrics()
+		y += float64(metrics.Ascent-metrics.Descent) / 64 / 2
+	}
+
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)},
+	}
+	d.DrawString(t.Content)
+}
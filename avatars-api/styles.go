@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// AvatarOptions carries the request parameters every generator may read.
+// Color, Palette and Background are empty unless the caller asked for them;
+// generators fall back to their own colors through accent, schemeColor and
+// background. Generators must take their randomness from digest, never from
+// the name directly, so seed, salt and hash apply to every style.
+type AvatarOptions struct {
+	Name       string
+	Size       string
+	Color      string
+	Palette    []string
+	Background string
+	Shape      string
+	Radius     float64
+	Initials   string
+	Seed       string
+	Salt       string
+	Hash       string
+	Version    int
+	Animate    bool
+	// Constellation is the ID of the figure picked with the constellation
+	// parameter, or empty to pick one from the name.
+	Constellation string
+	// Status, Badge and Ring (with RingWidth, a percentage of the size) are
+	// drawn over any style by applyOverlays.
+	Status    string
+	Badge     string
+	Ring      string
+	RingWidth float64
+	// Group lists the names composeGroup draws side by side, Layout how.
+	Group  []string
+	Layout string
+
+	// legacyInitials is set by withLegacyInitials for v1 of the lettered
+	// styles.
+	legacyInitials bool
+}
+
+// AvatarStyle describes one registered avatar generator.
+type AvatarStyle struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Options     []string                   `json:"options"`
+	Version     int                        `json:"version"`
+	Animated    bool                       `json:"animated"`
+	Generate    func(AvatarOptions) string `json:"-"`
+	// Legacy keeps the generators of earlier versions, so avatars pinned with
+	// `v` keep rendering the same after a style changes.
+	Legacy map[int]func(AvatarOptions) string `json:"-"`
+}
+
+var (
+	styleRegistry = map[string]*AvatarStyle{}
+	styleOrder    []string
+)
+
+// registerStyle adds a generator to the registry. The handler, the
+// documentation page and /api/styles all read from it, so registering is the
+// only step needed to ship a new style. Registration order is display order.
+func registerStyle(style AvatarStyle) {
+	if _, exists := styleRegistry[style.Name]; exists {
+		panic(fmt.Sprintf("avatar style %q registered twice", style.Name))
+	}
+	if style.Version == 0 {
+		style.Version = 1
+	}
+	styleRegistry[style.Name] = &style
+	styleOrder = append(styleOrder, style.Name)
+}
+
+func lookupStyle(name string) (*AvatarStyle, bool) {
+	style, ok := styleRegistry[name]
+	return style, ok
+}
+
+// generator returns the generator for a pinned version; 0 means the latest.
+func (s *AvatarStyle) generator(version int) (func(AvatarOptions) string, bool) {
+	if version == 0 || version == s.Version {
+		return s.Generate, true
+	}
+	generate, ok := s.Legacy[version]
+	return generate, ok
+}
+
+// withLegacyInitials wraps the generator of a lettered style as its v1, which
+// took initials from legacyInitials and drew only A-Z from the bitmap fonts.
+func withLegacyInitials(generate func(AvatarOptions) string) func(AvatarOptions) string {
+	return func(o AvatarOptions) string {
+		o.legacyInitials = true
+		return generate(o)
+	}
+}
+
+// animatedStyles lists the styles that honour animate=true.
+func animatedStyles() []string {
+	var names []string
+	for _, name := range styleOrder {
+		if styleRegistry[name].Animated {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// registeredStyles returns every style in registration order.
+func registeredStyles() []*AvatarStyle {
+	styles := make([]*AvatarStyle, 0, len(styleOrder))
+	for _, name := range styleOrder {
+		styles = append(styles, styleRegistry[name])
+	}
+	return styles
+}
+
+func stylesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(registeredStyles())
+}
+
+func init() {
+	common := []string{"name", "size", "color", "palette", "bg", "shape", "radius", "seed", "salt", "hash", "v", "status", "badge", "ring", "ringwidth", "group", "layout"}
+	lettered := append(common[:len(common):len(common)], "initials")
+	animated := append(common[:len(common):len(common)], "animate")
+
+	registerStyle(AvatarStyle{
+		Name:        "avatar",
+		Description: "Classic circular avatar with initials",
+		Options:     lettered,
+		Version:     2,
+		Generate:    generateInitialsAvatar,
+		Legacy: map[int]func(AvatarOptions) string{
+			1: withLegacyInitials(generateInitialsAvatar),
+		},
+	})
+	registerStyle(AvatarStyle{
+		Name:        "gravatar",
+		Description: "GitHub-style identicon",
+		Options:     common,
+		Generate:    generateGravatar,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "dither",
+		Description: "Retro dithered plasma effect",
+		Options:     common,
+		Generate: func(o AvatarOptions) string {
+			svgBody := generateDitheredAvatar(o)
+			return strings.Replace(svgBody, `width="100%" height="100%"`, fmt.Sprintf(`width="%s" height="%s"`, o.Size, o.Size), 1)
+		},
+	})
+	registerStyle(AvatarStyle{
+		Name:        "ascii",
+		Description: "Procedural ASCII robot",
+		Options:     common,
+		Generate:    generateAsciiRobot,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "dotmatrix",
+		Description: "LED dot matrix display",
+		Options:     append(lettered[:len(lettered):len(lettered)], "animate"),
+		Version:     2,
+		Animated:    true,
+		Generate:    generateDotMatrix,
+		Legacy: map[int]func(AvatarOptions) string{
+			1: withLegacyInitials(generateDotMatrix),
+		},
+	})
+	registerStyle(AvatarStyle{
+		Name:        "terminal",
+		Description: "Retro terminal block text",
+		Options:     lettered,
+		Version:     2,
+		Generate:    generateTerminalBlock,
+		Legacy: map[int]func(AvatarOptions) string{
+			1: withLegacyInitials(generateTerminalBlock),
+		},
+	})
+	registerStyle(AvatarStyle{
+		Name:        "bauhaus",
+		Description: "Geometric Bauhaus design",
+		Options:     common,
+		Generate:    generateBauhaus,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "ring",
+		Description: "Gradient ring pattern",
+		Options:     common,
+		Generate:    generateRing,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "beam",
+		Description: "Connected network nodes",
+		Options:     common,
+		Generate:    generateBeam,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "marble",
+		Description: "Marble texture effect",
+		Options:     animated,
+		Animated:    true,
+		Generate:    generateMarble,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "glitch",
+		Description: "Cyberpunk glitch effect",
+		Options:     append(lettered[:len(lettered):len(lettered)], "animate"),
+		Version:     2,
+		Animated:    true,
+		Generate:    generateGlitch,
+		Legacy: map[int]func(AvatarOptions) string{
+			1: withLegacyInitials(generateGlitch),
+		},
+	})
+	registerStyle(AvatarStyle{
+		Name:        "sunset",
+		Description: "Procedural sunset scene",
+		Options:     common,
+		Generate:    generateSunset,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "smile",
+		Description: "Minimalist face with expressions",
+		Options:     common,
+		Generate:    generateSmile,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "circuit",
+		Description: "Circuit board pattern",
+		Options:     common,
+		Generate:    generateCircuit,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "pixel",
+		Description: "Isometric pixel art cube",
+		Options:     common,
+		Generate:    generatePixel,
+	})
+	registerStyle(AvatarStyle{
+		Name:        "constellation",
+		Description: "Real constellation star maps",
+		Options:     append(animated[:len(animated):len(animated)], "constellation"),
+		Version:     2,
+		Animated:    true,
+		Generate:    generateGeoJSONAvatar,
+		Legacy: map[int]func(AvatarOptions) string{
+			1: func(o AvatarOptions) string { return drawConstellation(o, false) },
+		},
+	})
+}
+
+func generateInitialsAvatar(o AvatarOptions) string {
+	initials := html.EscapeString(o.initials())
+	generated := o.color("")
+	return fmt.Sprintf(`
+		<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 100 100">
+			%[4]s
+			<g transform="translate(5, 5) scale(0.9)">
+				<circle cx="50" cy="50" r="50" fill="%[2]s" />
+				<text x="50" y="55" dominant-baseline="middle" text-anchor="middle" font-family="Arial, sans-serif" font-size="40" fill="#ffffff">%[3]s</text>
+			</g>
+		</svg>`, o.Size, o.accent(generated), initials, o.backdrop())
+}
+
+func generateGravatar(o AvatarOptions) string {
+	generated := o.color("")
+	identiconRects := generateIdenticon(o.digest(""), o.accent(generated))
+	return fmt.Sprintf(`
+		<svg xmlns="http://www.w3.org/2000/svg" width="%[1]s" height="%[1]s" viewBox="0 0 250 250">
+			<rect width="100%%" height="100%%" fill="%[2]s" />
+			<g transform="translate(20, 10) scale(0.8)">
+				%[3]s
+			</g>	
+		</svg>`, o.Size, o.background("#11011D"), identiconRects)
+}
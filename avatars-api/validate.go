@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+const (
+	minAvatarSize = 1
+	maxSVGSize    = 4096
+	maxNameLength = 256
+)
+
+// fieldError is one invalid request parameter.
+type fieldError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// fieldErrors collects every invalid parameter of a request, so a client can
+// fix them all from one response instead of one per round trip.
+type fieldErrors []fieldError
+
+func (e *fieldErrors) add(param, format string, args ...any) {
+	*e = append(*e, fieldError{Param: param, Message: fmt.Sprintf(format, args...)})
+}
+
+// err turns the collected errors into a 400, or nil when there are none.
+func (e fieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	message := e[0].Message
+	if len(e) > 1 {
+		message = fmt.Sprintf("%d invalid parameters.", len(e))
+	}
+	return &avatarError{Status: http.StatusBadRequest, Message: message, Fields: e}
+}
+
+// errorBody is the JSON body of every error response from the avatar routes.
+type errorBody struct {
+	Error  string       `json:"error"`
+	Errors []fieldError `json:"errors,omitempty"`
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string, fields []fieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{Error: message, Errors: fields})
+}
+
+// parseSize reads size as an integer. SVG only needs a sane bound; raster
+// formats are limited by what we are willing to render.
+func parseSize(raw, format string, animated bool, errs *fieldErrors) int {
+	lo, hi := minAvatarSize, maxSVGSize
+	kind := ""
+	if _, raster := rasterFormats[format]; raster {
+		lo, hi, kind = minRasterSize, maxRasterSize, " for raster output"
+		if animated && (format == "gif" || format == "apng") {
+			hi, kind = maxAnimatedSize, " for animated output"
+		}
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < lo || n > hi {
+		errs.add("size", "Invalid size%s. Use an integer between %d and %d.", kind, lo, hi)
+		return 0
+	}
+	return n
+}
+
+// parseColor accepts a hex color, with or without '#', or an SVG named color.
+// Hex colors keep their case so existing URLs render the same bytes.
+func parseColor(raw string) (string, bool) {
+	if hexColorRegex.MatchString(raw) {
+		return "#" + strings.TrimPrefix(raw, "#"), true
+	}
+	name := strings.ToLower(raw)
+	if _, ok := colornames.Map[name]; ok {
+		return name, true
+	}
+	return "", false
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeText escapes a string for an SVG text node. Quotes are left alone as
+// they are only special inside attributes.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// checkSVG is a last line of defence for generated markup: it must be well
+// formed and free of scripts, event handlers and external references, whatever
+// a generator does with its input.
+func checkSVG(svgContent string) error {
+	decoder := xml.NewDecoder(strings.NewReader(svgContent))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("malformed svg: %w", err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(el.Name.Local) {
+		case "script", "foreignobject", "iframe", "image", "use":
+			return fmt.Errorf("svg contains <%s>", el.Name.Local)
+		}
+		for _, a := range el.Attr {
+			name := strings.ToLower(a.Name.Local)
+			if strings.HasPrefix(name, "on") {
+				return fmt.Errorf("svg contains event handler %s", a.Name.Local)
+			}
+			if name == "href" || strings.Contains(strings.ToLower(a.Value), "javascript:") {
+				return fmt.Errorf("svg contains a reference in %s", a.Name.Local)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+// stackedAreaSeries is one layer of a stacked area chart: the band between
+// the running total of the series below it, bases, and that total plus its
+// own values, tops. go-chart fills a ContinuousSeries down to the bottom of
+// the canvas, so stacked layers would cover each other.
+type stackedAreaSeries struct {
+	name  string
+	style chart.Style
+	bases []float64
+	tops  []float64
+}
+
+func (s stackedAreaSeries) GetName() string { return s.name }
+
+func (s stackedAreaSeries) GetYAxis() chart.YAxisType { return chart.YAxisPrimary }
+
+func (s stackedAreaSeries) GetStyle() chart.Style { return s.style }
+
+func (s stackedAreaSeries) Validate() error { return nil }
+
+// Len and GetBoundedValues let the chart fit its ranges to the bands.
+func (s stackedAreaSeries) Len() int { return len(s.tops) }
+
+func (s stackedAreaSeries) GetBoundedValues(i int) (x, y1, y2 float64) {
+	return float64(i), s.bases[i], s.tops[i]
+}
+
+func (s stackedAreaSeries) Render(r chart.Renderer, canvasBox chart.Box, xrange, yrange chart.Range, _ chart.Style) {
+	if len(s.tops) == 0 {
+		return
+	}
+	px := func(i int) int { return canvasBox.Left + xrange.Translate(float64(i)) }
+	py := func(v float64) int { return canvasBox.Bottom - yrange.Translate(v) }
+
+	r.SetFillColor(s.style.FillColor)
+	r.SetStrokeWidth(0)
+	r.MoveTo(px(0), py(s.tops[0]))
+	for i := 1; i < len(s.tops); i++ {
+		r.LineTo(px(i), py(s.tops[i]))
+	}
+	for i := len(s.bases) - 1; i >= 0; i-- {
+		r.LineTo(px(i), py(s.bases[i]))
+	}
+	r.Close()
+	r.Fill()
+
+	s.style.GetStrokeOptions().WriteToRenderer(r)
+	r.MoveTo(px(0), py(s.tops[0]))
+	for i := 1; i < len(s.tops); i++ {
+		r.LineTo(px(i), py(s.tops[i]))
+	}
+	r.Stroke()
+}
+
+// stackAreas turns the values of each series, padded with zeros to points,
+// into cumulative bands. With normalized set every point is a share of its
+// column's total, in percent, so the stack always reaches 100.
+func stackAreas(values [][]float64, points int, normalized bool) (bases, tops [][]float64) {
+	totals := make([]float64, points)
+	if normalized {
+		for _, vs := range values {
+			for i, v := range vs {
+				totals[i] += v
+			}
+		}
+	}
+
+	running := make([]float64, points)
+	for _, vs := range values {
+		base := make([]float64, points)
+		top := make([]float64, points)
+		for i := range points {
+			v := 0.0
+			if i < len(vs) {
+				v = vs[i]
+			}
+			if normalized && totals[i] != 0 {
+				v = v / totals[i] * 100
+			}
+			base[i] = running[i]
+			running[i] += v
+			top[i] = running[i]
+		}
+		bases = append(bases, base)
+		tops = append(tops, top)
+	}
+	return bases, tops
+}
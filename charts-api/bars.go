@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+// barGroupWidth is the share of a category's slot its bars take up, leaving
+// a gap between categories.
+const barGroupWidth = 0.8
+
+// barSeries is one series of a bar chart, drawn as a series of a chart.Chart
+// so bar charts get the axes and legend line charts have. go-chart's own
+// BarChart only draws a single series, and its StackedBarChart only shows
+// each bar's proportions.
+//
+// Category i sits at i on the category axis, which runs top to bottom when
+// the bars are horizontal. A bar spans from bases[i] to bases[i]+values[i];
+// stacked series pass the running totals of the series below as bases.
+type barSeries struct {
+	name       string
+	style      chart.Style
+	values     []float64
+	bases      []float64
+	slot       int
+	slots      int
+	horizontal bool
+}
+
+func (s barSeries) GetName() string { return s.name }
+
+func (s barSeries) GetYAxis() chart.YAxisType { return chart.YAxisPrimary }
+
+func (s barSeries) GetStyle() chart.Style { return s.style }
+
+func (s barSeries) Validate() error { return nil }
+
+func (s barSeries) Render(r chart.Renderer, canvasBox chart.Box, xrange, yrange chart.Range, _ chart.Style) {
+	width := barGroupWidth / float64(s.slots)
+	style := chart.Style{
+		FillColor:   s.style.StrokeColor,
+		StrokeColor: s.style.StrokeColor,
+		StrokeWidth: 1,
+	}
+	for i, v := range s.values {
+		start := float64(i) - barGroupWidth/2 + float64(s.slot)*width
+		base := 0.0
+		if s.bases != nil {
+			base = s.bases[i]
+		}
+
+		var box chart.Box
+		if s.horizontal {
+			box = chart.Box{
+				Left:   canvasBox.Left + xrange.Translate(math.Min(base, base+v)),
+				Right:  canvasBox.Left + xrange.Translate(math.Max(base, base+v)),
+				Top:    canvasBox.Bottom - yrange.Translate(-start),
+				Bottom: canvasBox.Bottom - yrange.Translate(-start-width),
+			}
+		} else {
+			box = chart.Box{
+				Left:   canvasBox.Left + xrange.Translate(start),
+				Right:  canvasBox.Left + xrange.Translate(start+width),
+				Top:    canvasBox.Bottom - yrange.Translate(math.Max(base, base+v)),
+				Bottom: canvasBox.Bottom - yrange.Translate(math.Min(base, base+v)),
+			}
+		}
+		if v != 0 {
+			chart.Draw.Box(r, box, style)
+		}
+	}
+}
+
+// stackBars sets the bases of series so each stacks on those before it.
+// Positive and negative values stack away from zero separately. It returns
+// the range the stacks cover.
+func stackBars(series []barSeries, categories int) (low, high float64) {
+	pos := make([]float64, categories)
+	neg := make([]float64, categories)
+	for i := range series {
+		series[i].bases = make([]float64, categories)
+		for c, v := range series[i].values {
+			if v >= 0 {
+				series[i].bases[c] = pos[c]
+				pos[c] += v
+			} else {
+				series[i].bases[c] = neg[c]
+				neg[c] += v
+			}
+		}
+	}
+	for c := range categories {
+		low, high = math.Min(low, neg[c]), math.Max(high, pos[c])
+	}
+	return low, high
+}
+
+// categoryTicks labels the category axis. Vertical bars put each label on
+// the tick after its category, for the x axis to centre it between ticks with
+// TickPositionBetweenTicks. Horizontal bars centre category i on -i, so the
+// first comes out on top, between unlabelled ticks that leave room for the
+// outer bars.
+func categoryTicks(labels []string, horizontal bool) []chart.Tick {
+	n := len(labels)
+	if !horizontal {
+		ticks := []chart.Tick{{Value: -0.5}}
+		for i, label := range labels {
+			ticks = append(ticks, chart.Tick{Value: float64(i) + 0.5, Label: label})
+		}
+		return ticks
+	}
+	ticks := []chart.Tick{{Value: 0.5 - float64(n)}}
+	for i := n - 1; i >= 0; i-- {
+		ticks = append(ticks, chart.Tick{Value: -float64(i), Label: labels[i]})
+	}
+	return append(ticks, chart.Tick{Value: 0.5})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxChartBodyBytes bounds a POSTed config, measured after gzip decoding so a
+// small compressed body can't expand without limit.
+const maxChartBodyBytes = 4 << 20
+
+// requestError is a client error together with the status to answer it with.
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string { return e.message }
+
+// readChartConfig returns the JSON config of a chart request: the body of a
+// POST, or the base64 data parameter of a GET.
+func readChartConfig(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if r.Method == http.MethodPost {
+		return readConfigBody(w, r)
+	}
+
+	encodedData := r.URL.Query().Get("data")
+	if encodedData == "" {
+		return nil, &requestError{http.StatusBadRequest, "Missing 'data' parameter"}
+	}
+	decoded, err := decodeConfigParam(encodedData)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid base64 encoding: " + err.Error()}
+	}
+	return decoded, nil
+}
+
+// decodeConfigParam decodes URL-safe base64 with or without its trailing
+// padding; most encoders outside Go's standard library drop it.
+func decodeConfigParam(encoded string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+}
+
+// readConfigBody reads an application/json body, gzip-encoded or not, of at
+// most maxChartBodyBytes.
+func readConfigBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return nil, &requestError{http.StatusUnsupportedMediaType, "Send the chart config with Content-Type: application/json"}
+	}
+
+	body := io.Reader(http.MaxBytesReader(w, r.Body, maxChartBodyBytes))
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, bodyError(err)
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, &requestError{http.StatusUnsupportedMediaType, "Unsupported Content-Encoding: " + encoding + ". Use gzip or none."}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxChartBodyBytes+1))
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	if len(data) > maxChartBodyBytes {
+		return nil, errBodyTooLarge
+	}
+	if len(data) == 0 {
+		return nil, &requestError{http.StatusBadRequest, "Missing chart config in the request body"}
+	}
+	return data, nil
+}
+
+var errBodyTooLarge = &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Chart config is too large. The limit is %d bytes.", maxChartBodyBytes)}
+
+// errChartTooLarge refuses an update that leaves a saved chart over the limit.
+var errChartTooLarge = &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Chart config would grow past %d bytes. Trim it with keep, or replace the data.", maxChartBodyBytes)}
+
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errBodyTooLarge
+	}
+	return &requestError{http.StatusBadRequest, "Invalid request body: " + err.Error()}
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
-	"encoding/base64"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/cache"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/negotiate"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/server"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 	"github.com/wcharczuk/go-chart/v2"
 	"github.com/wcharczuk/go-chart/v2/drawing"
 )
@@ -17,10 +29,18 @@ import (
 // ChartConfig represents the configuration for any chart type
 type ChartConfig struct {
 	Type   string          `json:"type"`
-	Title  string          `json:"title"`
-	Width  int             `json:"width"`
-	Height int             `json:"height"`
+	Title  string          `json:"title,omitempty"`
+	Width  int             `json:"width,omitempty"`
+	Height int             `json:"height,omitempty"`
 	Data   json.RawMessage `json:"data"`
+	// Format is "svg", "png" or "json", for links that can't send an Accept
+	// header. A format query parameter still wins.
+	Format string `json:"format,omitempty"`
+	// Scale multiplies the pixel size of the output, keeping the layout:
+	// scale 2 renders an 800x600 chart as a sharp 1600x1200 PNG. DPI is the
+	// same for print, with 96 dpi being scale 1. Set at most one of them.
+	Scale float64 `json:"scale,omitempty"`
+	DPI   float64 `json:"dpi,omitempty"`
 }
 
 // LineChartData represents line chart specific data
@@ -34,6 +54,8 @@ type AreaChartData struct {
 	XAxis   []string     `json:"xAxis"`
 	Series  []SeriesData `json:"series"`
 	Stacked bool         `json:"stacked,omitempty"`
+	// Normalized stacks each point as a percentage of its column's total.
+	Normalized bool `json:"normalized,omitempty"`
 }
 
 // BarChartData represents bar chart data
@@ -73,21 +95,49 @@ type PieItem struct {
 	Value float64 `json:"value"`
 }
 
-func main() {
-	r := chi.NewRouter()
+// chartCache holds rendered charts by a hash of their decoded config, so the
+// same embed in many READMEs is only drawn once.
+var chartCache *cache.Cache
 
-	// Middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.RealIP)
+func main() {
+	// /health keeps its JSON body rather than the shared heartbeat.
+	cfg := server.Defaults("charts-api", "8002")
+	cfg.Heartbeat = ""
+	// Dashboards update saved charts from the browser with PUT and a bearer
+	// token, and may gzip their configs.
+	cfg.CORSMethods = append(cfg.CORSMethods, "PUT")
+	cfg.CORSHeaders = []string{"Accept", "Content-Type", "Content-Encoding", "Authorization"}
+	r := server.New(cfg)
+
+	var err error
+	chartCache, err = cache.FromEnv(cache.Options{Namespace: "charts", TTL: 24 * time.Hour})
+	if err != nil {
+		slog.Error("❌ Invalid cache settings", "err", err)
+		os.Exit(1)
+	}
+	metrics.RegisterCache(chartCache)
+	charts, err = openChartStore(cmp.Or(os.Getenv("CHARTS_DB"), "./charts.db"))
+	if err != nil {
+		slog.Error("❌ Failed to open chart store", "err", err)
+		os.Exit(1)
+	}
+	r.OnShutdown(charts.Close)
+	r.OnShutdown(chartCache.Close)
 
 	// Routes
 	r.Get("/", documentationHandler)
 	r.Get("/chart", chartHandler)
+	r.Post("/chart", chartHandler)
+	r.Post("/charts", createChartHandler)
+	r.Get("/charts/{id}", chartInfoHandler)
+	r.Put("/charts/{id}/data", updateChartDataHandler)
+	r.Get("/c/{file}", storedChartHandler)
 	r.Get("/health", healthHandler)
 
-	log.Println("Charts API Server starting on :8002")
-	log.Fatal(http.ListenAndServe(":8002", r))
+	if err := r.Run(); err != nil {
+		slog.Error("❌ Server failed", "err", err)
+		os.Exit(1)
+	}
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -350,10 +400,23 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 						<td><code>data</code></td>
 						<td>string</td>
 						<td>Yes</td>
-						<td>Base64 URL-encoded JSON configuration</td>
+						<td>Base64 URL-encoded JSON configuration, padding optional</td>
 					</tr>
 				</tbody>
 			</table>
+
+			<div class="endpoint"><span class="method">POST</span> /chart</div>
+			<p>Generates the same chart from a JSON configuration sent as the request body with <code>Content-Type: application/json</code>, for datasets too large for a URL. Bodies may be sent with <code>Content-Encoding: gzip</code> and are limited to 4 MB once decompressed.</p>
+
+			<h3>Stored Charts</h3>
+			<div class="endpoint"><span class="method">POST</span> /charts</div>
+			<p>Saves a JSON configuration and returns its short <code>id</code>, embed URLs and an edit <code>token</code>, shown only this once.</p>
+			<div class="endpoint"><span class="method">GET</span> /c/{id}.svg</div>
+			<p>Renders a saved chart; <code>.png</code> and <code>.json</code> work too. The URL stays the same when the data changes.</p>
+			<div class="endpoint"><span class="method">GET</span> /charts/{id}</div>
+			<p>Returns the saved configuration and URLs.</p>
+			<div class="endpoint"><span class="method">PUT</span> /charts/{id}/data?mode=replace|append&amp;keep={n}</div>
+			<p>Replaces the chart's <code>data</code> with the JSON body, or appends labels and points to the series with the same names. <code>keep</code> trims each series to its last points. Send the edit token as <code>Authorization: Bearer {token}</code>.</p>
 		</div>
 
 		<div class="section">
@@ -437,6 +500,24 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 						<td>-</td>
 						<td>Chart-specific data configuration</td>
 					</tr>
+					<tr>
+						<td><code>format</code></td>
+						<td>string</td>
+						<td>"svg"</td>
+						<td>Output format: "svg", "png" or "json", for when you can't send an Accept header</td>
+					</tr>
+					<tr>
+						<td><code>scale</code></td>
+						<td>number</td>
+						<td>1</td>
+						<td>Pixel multiplier for sharp PNGs (up to 8), keeping the layout</td>
+					</tr>
+					<tr>
+						<td><code>dpi</code></td>
+						<td>number</td>
+						<td>96</td>
+						<td>Print resolution instead of scale; 300 dpi is scale 3.125</td>
+					</tr>
 				</tbody>
 			</table>
 
@@ -451,6 +532,8 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
     }
   ]
 }</pre>
+			<p>Bar charts draw several series side by side. Add <code>"stacked": true</code> to stack them, or <code>"horizontal": true</code> for horizontal bars.</p>
+			<p>Area charts take <code>"stacked": true</code> to stack their series, and <code>"normalized": true</code> to show each point as a share of 100%.</p>
 
 			<h3>Pie Chart Data</h3>
 			<pre>{
@@ -487,7 +570,7 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 
 		<div class="section">
 			<h2>📝 Response Format</h2>
-			<p><strong>Content-Type:</strong> <code>image/svg+xml</code></p>
+			<p><strong>Content-Type:</strong> <code>image/svg+xml</code> by default. Send <code>Accept: image/png</code> or <code>Accept: application/json</code> to receive a PNG or a JSON object with the SVG markup instead; responses carry <code>Vary: Accept</code>. A <code>format</code> field in the config does the same for plain links, and <code>scale</code> or <code>dpi</code> render PNGs with more pixels.</p>
 			<p><strong>Cache-Control:</strong> <code>public, max-age=3600</code></p>
 			<p style="margin-top: 1rem">All charts return pure SVG that can be embedded directly in HTML, documents, or downloaded as files.</p>
 			
@@ -617,64 +700,182 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(apiDocsHTML))
 }
 
+// chartOffers lists the formats chartHandler can negotiate via the Accept
+// header, in order of preference.
+var chartOffers = []negotiate.Offer{
+	{Format: "svg", MediaType: "image/svg+xml"},
+	{Format: "png", MediaType: "image/png"},
+	{Format: "json", MediaType: "application/json"},
+}
+
+// chartJSON is the application/json representation of a rendered chart.
+type chartJSON struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	SVG    string `json:"svg"`
+}
+
+// chartHandler serves GET /chart with the config in the data parameter and
+// POST /chart with it as the body. Both render the same chart for the same
+// config, and share cache entries.
 func chartHandler(w http.ResponseWriter, r *http.Request) {
-	// Get encoded data from query parameter
-	encodedData := r.URL.Query().Get("data")
-	if encodedData == "" {
-		http.Error(w, "Missing 'data' parameter", http.StatusBadRequest)
+	decodedBytes, err := readChartConfig(w, r)
+	if err != nil {
+		writeRequestError(w, err)
 		return
 	}
+	serveChart(w, r, decodedBytes, "", "public, max-age=3600")
+}
 
-	// Decode base64
-	decodedBytes, err := base64.URLEncoding.DecodeString(encodedData)
-	if err != nil {
-		http.Error(w, "Invalid base64 encoding: "+err.Error(), http.StatusBadRequest)
+// writeRequestError answers with the status of a *requestError, and 400 for
+// any other error.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		http.Error(w, reqErr.message, reqErr.status)
 		return
 	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
 
+// serveChart renders the chart configured by decodedBytes. format overrides
+// the config and the request when set, as the extension of a stored chart's
+// URL does.
+func serveChart(w http.ResponseWriter, r *http.Request, decodedBytes []byte, format, cacheControl string) {
 	// Parse chart config
 	var config ChartConfig
 	if err := json.Unmarshal(decodedBytes, &config); err != nil {
 		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	config.setDefaults()
+
+	w.Header().Add("Vary", "Accept")
+
+	var contentType string
+	var renderer chart.RendererProvider
+	if format == "" {
+		format = strings.ToLower(config.Format)
+		if format == "" || r.URL.Query().Has("format") {
+			format = negotiate.Format(r, chartOffers)
+		}
+	}
+	switch format {
+	case "svg", "json":
+		contentType = "image/svg+xml"
+		renderer = chart.SVG
+	case "png":
+		contentType = "image/png"
+		renderer = chart.PNG
+	default:
+		http.Error(w, "Unsupported format: "+format, http.StatusBadRequest)
+		return
+	}
+	scale, err := config.outputScale(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	renderer = scaled(renderer, scale)
+	if format == "json" {
+		contentType = "application/json"
+	}
 
-	// Set defaults
-	if config.Width == 0 {
-		config.Width = 800
+	sum := sha256.Sum256(decodedBytes)
+	cacheKey := format + ":" + hex.EncodeToString(sum[:])
+	// The config decides the bytes, so its hash makes a strong validator.
+	etag := `"` + format + "-" + hex.EncodeToString(sum[:8]) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
-	if config.Height == 0 {
-		config.Height = 600
+
+	if body, found := chartCache.Get(cacheKey); found {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("X-Cache", "HIT")
+		w.Write(body)
+		return
 	}
 
 	// Generate chart based on type
-	var err2 error
-	w.Header().Set("Content-Type", "image/svg+xml")
-	w.Header().Set("Cache-Control", "public, max-age=3600")
+	var buf bytes.Buffer
+	_, span := tracing.Start(r.Context(), "render "+format+" chart")
+	err = renderChart(&buf, config, renderer)
+	tracing.End(span, err)
+	if errors.Is(err, errUnsupportedType) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error generating chart: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	body := buf.Bytes()
+	if format == "json" {
+		body, err = json.Marshal(chartJSON{
+			Type:   config.Type,
+			Title:  config.Title,
+			Width:  config.Width,
+			Height: config.Height,
+			SVG:    buf.String(),
+		})
+		if err != nil {
+			http.Error(w, "Error encoding chart: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	chartCache.Set(cacheKey, body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Cache", "MISS")
+	w.Write(body)
+}
+
+// setDefaults fills in the size of a config that leaves it out.
+func (c *ChartConfig) setDefaults() {
+	if c.Width == 0 {
+		c.Width = 800
+	}
+	if c.Height == 0 {
+		c.Height = 600
+	}
+}
+
+var errUnsupportedType = errors.New("Unsupported chart type")
+
+// hiddenAxis hides go-chart's secondary y axis, which no chart here uses.
+// Shown, it is drawn from an empty range, and whenever the tick count changes
+// (as it does with scale) its ticks land on NaN coordinates that stop the PNG
+// renderer from drawing anything.
+var hiddenAxis = chart.YAxis{Style: chart.Hidden()}
+
+// renderChart draws config with the builder for its type.
+func renderChart(w io.Writer, config ChartConfig, renderer chart.RendererProvider) error {
 	switch strings.ToLower(config.Type) {
 	case "line":
-		err2 = generateLineChart(w, config)
+		return generateLineChart(w, config, renderer)
 	case "area":
-		err2 = generateAreaChart(w, config)
+		return generateAreaChart(w, config, renderer)
 	case "bar":
-		err2 = generateBarChart(w, config)
+		return generateBarChart(w, config, renderer)
 	case "pie":
-		err2 = generatePieChart(w, config)
+		return generatePieChart(w, config, renderer)
 	case "scatter":
-		err2 = generateScatterChart(w, config)
-	default:
-		http.Error(w, "Unsupported chart type: "+config.Type, http.StatusBadRequest)
-		return
-	}
-
-	if err2 != nil {
-		http.Error(w, "Error generating chart: "+err2.Error(), http.StatusInternalServerError)
-		return
+		return generateScatterChart(w, config, renderer)
 	}
+	return fmt.Errorf("%w: %s", errUnsupportedType, config.Type)
 }
 
-func generateLineChart(w http.ResponseWriter, config ChartConfig) error {
+func generateLineChart(w io.Writer, config ChartConfig, renderer chart.RendererProvider) error {
 	var data LineChartData
 	if err := json.Unmarshal(config.Data, &data); err != nil {
 		return err
@@ -701,6 +902,7 @@ func generateLineChart(w http.ResponseWriter, config ChartConfig) error {
 				FontSize: 10,
 			},
 		},
+		YAxisSecondary: hiddenAxis,
 	}
 
 	colors := []drawing.Color{
@@ -741,10 +943,10 @@ func generateLineChart(w http.ResponseWriter, config ChartConfig) error {
 		chart.Legend(&graph),
 	}
 
-	return graph.Render(chart.SVG, w)
+	return graph.Render(renderer, w)
 }
 
-func generateAreaChart(w http.ResponseWriter, config ChartConfig) error {
+func generateAreaChart(w io.Writer, config ChartConfig, renderer chart.RendererProvider) error {
 	var data AreaChartData
 	if err := json.Unmarshal(config.Data, &data); err != nil {
 		return err
@@ -771,11 +973,18 @@ func generateAreaChart(w http.ResponseWriter, config ChartConfig) error {
 				FontSize: 10,
 			},
 		},
+		YAxisSecondary: hiddenAxis,
 	}
 
 	colors := []drawing.Color{
 		drawing.Color{R: 102, G: 126, B: 234, A: 255},
 		drawing.Color{R: 237, G: 100, B: 166, A: 255},
+		drawing.Color{R: 255, G: 159, B: 64, A: 255},
+		drawing.Color{R: 75, G: 192, B: 192, A: 255},
+	}
+
+	if data.Stacked || data.Normalized {
+		return renderStackedArea(w, graph, data, colors, renderer)
 	}
 
 	for idx, series := range data.Series {
@@ -787,15 +996,7 @@ func generateAreaChart(w http.ResponseWriter, config ChartConfig) error {
 			yValues[i] = toFloat64(val)
 		}
 
-		color := colors[idx%len(colors)]
-		if series.Color != "" {
-			if c, err := parseHexColor(series.Color); err == nil {
-				color = c
-			}
-		}
-
-		fillColor := color
-		fillColor.A = 100
+		color, fillColor := areaColors(series, colors[idx%len(colors)])
 
 		graph.Series = append(graph.Series, chart.ContinuousSeries{
 			Name:    series.Name,
@@ -813,50 +1014,178 @@ func generateAreaChart(w http.ResponseWriter, config ChartConfig) error {
 		chart.Legend(&graph),
 	}
 
-	return graph.Render(chart.SVG, w)
+	return graph.Render(renderer, w)
 }
 
-func generateBarChart(w http.ResponseWriter, config ChartConfig) error {
+// renderStackedArea draws the series of data on top of each other, from the
+// first series up.
+func renderStackedArea(w io.Writer, graph chart.Chart, data AreaChartData, colors []drawing.Color, renderer chart.RendererProvider) error {
+	points := 0
+	values := make([][]float64, len(data.Series))
+	for idx, series := range data.Series {
+		values[idx] = make([]float64, len(series.Data))
+		for i, val := range series.Data {
+			values[idx][i] = toFloat64(val)
+		}
+		points = max(points, len(series.Data))
+	}
+	bases, tops := stackAreas(values, points, data.Normalized)
+
+	if data.Normalized {
+		graph.YAxis.Range = &chart.ContinuousRange{Min: 0, Max: 100}
+		graph.YAxis.ValueFormatter = func(v interface{}) string {
+			return fmt.Sprintf("%.0f%%", v.(float64))
+		}
+	}
+
+	for idx, series := range data.Series {
+		color, fillColor := areaColors(series, colors[idx%len(colors)])
+		graph.Series = append(graph.Series, stackedAreaSeries{
+			name: series.Name,
+			style: chart.Style{
+				StrokeColor: color,
+				StrokeWidth: 2,
+				FillColor:   fillColor,
+			},
+			bases: bases[idx],
+			tops:  tops[idx],
+		})
+	}
+
+	graph.Elements = []chart.Renderable{
+		chart.Legend(&graph),
+	}
+
+	return graph.Render(renderer, w)
+}
+
+// areaColors returns the line and fill colors of an area series: its own
+// color when it has a valid one, else fallback.
+func areaColors(series SeriesData, fallback drawing.Color) (color, fillColor drawing.Color) {
+	color = fallback
+	if series.Color != "" {
+		if c, err := parseHexColor(series.Color); err == nil {
+			color = c
+		}
+	}
+	fillColor = color
+	fillColor.A = 100
+	return color, fillColor
+}
+
+func generateBarChart(w io.Writer, config ChartConfig, renderer chart.RendererProvider) error {
 	var data BarChartData
 	if err := json.Unmarshal(config.Data, &data); err != nil {
 		return err
 	}
 
 	if len(data.Series) == 0 {
-		return nil
+		return errors.New("bar chart needs at least one series")
 	}
 
-	bars := []chart.Value{}
-	for i, label := range data.XAxis {
-		value := 0.0
-		if i < len(data.Series[0].Data) {
-			value = toFloat64(data.Series[0].Data[i])
+	// Series longer than xAxis get unlabelled categories.
+	labels := data.XAxis
+	for _, series := range data.Series {
+		for len(labels) < len(series.Data) {
+			labels = append(labels, "")
 		}
-		bars = append(bars, chart.Value{
-			Label: label,
-			Value: value,
-		})
 	}
 
-	graph := chart.BarChart{
+	colors := []drawing.Color{
+		drawing.Color{R: 102, G: 126, B: 234, A: 255},
+		drawing.Color{R: 237, G: 100, B: 166, A: 255},
+		drawing.Color{R: 255, G: 159, B: 64, A: 255},
+		drawing.Color{R: 75, G: 192, B: 192, A: 255},
+	}
+
+	bars := make([]barSeries, len(data.Series))
+	named := false
+	for idx, series := range data.Series {
+		values := make([]float64, len(labels))
+		for i, val := range series.Data {
+			values[i] = toFloat64(val)
+		}
+
+		color := colors[idx%len(colors)]
+		if series.Color != "" {
+			if c, err := parseHexColor(series.Color); err == nil {
+				color = c
+			}
+		}
+
+		bars[idx] = barSeries{
+			name: series.Name,
+			// The legend draws its key with the stroke.
+			style: chart.Style{
+				StrokeColor: color,
+				StrokeWidth: 8,
+			},
+			values:     values,
+			slot:       idx,
+			slots:      len(data.Series),
+			horizontal: data.Horizontal,
+		}
+		named = named || series.Name != ""
+	}
+
+	// The value axis always includes zero, where the bars start.
+	low, high := 0.0, 0.0
+	if data.Stacked {
+		low, high = stackBars(bars, len(labels))
+		for i := range bars {
+			bars[i].slot, bars[i].slots = 0, 1
+		}
+	} else {
+		for _, b := range bars {
+			for _, v := range b.values {
+				low, high = math.Min(low, v), math.Max(high, v)
+			}
+		}
+	}
+	if low == high {
+		high = 1
+	}
+	values := &chart.ContinuousRange{Min: low, Max: high}
+
+	graph := chart.Chart{
 		Title:  config.Title,
 		Width:  config.Width,
 		Height: config.Height,
-		Bars:   bars,
-		XAxis: chart.Style{
-			FontSize: 10,
+		XAxis: chart.XAxis{
+			Style: chart.Style{
+				FontSize: 10,
+			},
 		},
 		YAxis: chart.YAxis{
 			Style: chart.Style{
 				FontSize: 10,
 			},
 		},
+		YAxisSecondary: hiddenAxis,
+	}
+	if data.Horizontal {
+		graph.XAxis.Range = values
+		graph.YAxis.Ticks = categoryTicks(labels, true)
+	} else {
+		graph.XAxis.Ticks = categoryTicks(labels, false)
+		graph.XAxis.TickPosition = chart.TickPositionBetweenTicks
+		graph.YAxis.Range = values
+	}
+
+	for _, b := range bars {
+		graph.Series = append(graph.Series, b)
+	}
+
+	if named {
+		graph.Elements = []chart.Renderable{
+			chart.Legend(&graph),
+		}
 	}
 
-	return graph.Render(chart.SVG, w)
+	return graph.Render(renderer, w)
 }
 
-func generatePieChart(w http.ResponseWriter, config ChartConfig) error {
+func generatePieChart(w io.Writer, config ChartConfig, renderer chart.RendererProvider) error {
 	var data PieChartData
 	if err := json.Unmarshal(config.Data, &data); err != nil {
 		return err
@@ -877,10 +1206,10 @@ func generatePieChart(w http.ResponseWriter, config ChartConfig) error {
 		Values: values,
 	}
 
-	return graph.Render(chart.SVG, w)
+	return graph.Render(renderer, w)
 }
 
-func generateScatterChart(w http.ResponseWriter, config ChartConfig) error {
+func generateScatterChart(w io.Writer, config ChartConfig, renderer chart.RendererProvider) error {
 	var data ScatterChartData
 	if err := json.Unmarshal(config.Data, &data); err != nil {
 		return err
@@ -900,6 +1229,7 @@ func generateScatterChart(w http.ResponseWriter, config ChartConfig) error {
 				FontSize: 10,
 			},
 		},
+		YAxisSecondary: hiddenAxis,
 	}
 
 	colors := []drawing.Color{
@@ -936,7 +1266,7 @@ func generateScatterChart(w http.ResponseWriter, config ChartConfig) error {
 		chart.Legend(&graph),
 	}
 
-	return graph.Render(chart.SVG, w)
+	return graph.Render(renderer, w)
 }
 
 // Helper functions
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+const (
+	// cssDPI is the resolution scale 1 stands for, so dpi=300 is scale 3.125.
+	cssDPI   = 96.0
+	maxScale = 8.0
+	// maxChartPixels bounds width*height after scaling; a 4096x4096 bitmap
+	// is already 64MB in memory.
+	maxChartPixels = 4096 * 4096
+)
+
+// scaled returns a provider whose renderers draw the chart laid out at
+// width x height onto a canvas scale times larger. go-chart lays a chart out
+// in pixels, so just raising Width and Height would squeeze the fonts,
+// strokes and padding of a high-DPI PNG; this keeps the layout and scales
+// everything drawn.
+func scaled(provider chart.RendererProvider, scale float64) chart.RendererProvider {
+	if scale == 1 {
+		return provider
+	}
+	return func(width, height int) (chart.Renderer, error) {
+		r, err := provider(int(math.Round(float64(width)*scale)), int(math.Round(float64(height)*scale)))
+		if err != nil {
+			return nil, err
+		}
+		return &scaledRenderer{Renderer: r, scale: scale, dpi: chart.DefaultDPI}, nil
+	}
+}
+
+// scaledRenderer multiplies coordinates, sizes and the DPI (which sets the
+// font size in pixels) on the way in, and divides text measurements on the
+// way out.
+type scaledRenderer struct {
+	chart.Renderer
+	scale float64
+	dpi   float64
+}
+
+func (s *scaledRenderer) px(v int) int { return int(math.Round(float64(v) * s.scale)) }
+
+func (s *scaledRenderer) GetDPI() float64 { return s.dpi }
+
+func (s *scaledRenderer) SetDPI(dpi float64) {
+	s.dpi = dpi
+	s.Renderer.SetDPI(dpi * s.scale)
+}
+
+func (s *scaledRenderer) SetStrokeWidth(width float64) {
+	s.Renderer.SetStrokeWidth(width * s.scale)
+}
+
+func (s *scaledRenderer) SetStrokeDashArray(dashArray []float64) {
+	scaledDashes := make([]float64, len(dashArray))
+	for i, d := range dashArray {
+		scaledDashes[i] = d * s.scale
+	}
+	s.Renderer.SetStrokeDashArray(scaledDashes)
+}
+
+func (s *scaledRenderer) MoveTo(x, y int) { s.Renderer.MoveTo(s.px(x), s.px(y)) }
+
+func (s *scaledRenderer) LineTo(x, y int) { s.Renderer.LineTo(s.px(x), s.px(y)) }
+
+func (s *scaledRenderer) QuadCurveTo(cx, cy, x, y int) {
+	s.Renderer.QuadCurveTo(s.px(cx), s.px(cy), s.px(x), s.px(y))
+}
+
+func (s *scaledRenderer) ArcTo(cx, cy int, rx, ry, startAngle, delta float64) {
+	s.Renderer.ArcTo(s.px(cx), s.px(cy), rx*s.scale, ry*s.scale, startAngle, delta)
+}
+
+func (s *scaledRenderer) Circle(radius float64, x, y int) {
+	s.Renderer.Circle(radius*s.scale, s.px(x), s.px(y))
+}
+
+func (s *scaledRenderer) Text(body string, x, y int) { s.Renderer.Text(body, s.px(x), s.px(y)) }
+
+// MeasureText rounds outwards, so labels laid out with it never overlap. An
+// empty string measures as a box ending at math.MinInt64 in the PNG renderer,
+// a sentinel go-chart expects as is, so boxes that end before they start are
+// passed through unscaled.
+func (s *scaledRenderer) MeasureText(body string) chart.Box {
+	b := s.Renderer.MeasureText(body)
+	if b.Right < b.Left || b.Bottom < b.Top {
+		return b
+	}
+	return chart.Box{
+		Top:    int(math.Floor(float64(b.Top) / s.scale)),
+		Left:   int(math.Floor(float64(b.Left) / s.scale)),
+		Right:  int(math.Ceil(float64(b.Right) / s.scale)),
+		Bottom: int(math.Ceil(float64(b.Bottom) / s.scale)),
+		IsSet:  b.IsSet,
+	}
+}
+
+// outputScale resolves Scale or DPI to the factor passed to scaled, and
+// checks a PNG stays within maxChartPixels once scaled. SVG output is only
+// text, so its size is left alone.
+func (c ChartConfig) outputScale(format string) (float64, error) {
+	if c.Scale != 0 && c.DPI != 0 {
+		return 0, errors.New("Set either scale or dpi, not both")
+	}
+	scale := c.Scale
+	if c.DPI != 0 {
+		scale = c.DPI / cssDPI
+	}
+	if scale == 0 {
+		scale = 1
+	}
+	if !(scale > 0 && scale <= maxScale) {
+		return 0, fmt.Errorf("Invalid scale: must be above 0 and at most %g (dpi %g)", maxScale, maxScale*cssDPI)
+	}
+	if c.Width <= 0 || c.Height <= 0 {
+		return 0, fmt.Errorf("Invalid size: %dx%d", c.Width, c.Height)
+	}
+	if format != "png" {
+		return scale, nil
+	}
+	w, h := math.Round(float64(c.Width)*scale), math.Round(float64(c.Height)*scale)
+	if w*h > maxChartPixels {
+		return 0, fmt.Errorf("Chart too large: %gx%g pixels, the limit is %d pixels in total", w, h, maxChartPixels)
+	}
+	return scale, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+// scaleTestCharts has one config per chart type, and the bar and area
+// variants with their own layout code.
+var scaleTestCharts = map[string]string{
+	"line":           `{"type":"line","title":"Line","data":{"xAxis":["Jan","Feb","Mar","Apr"],"series":[{"name":"a","data":[1,3,2,5]},{"name":"b","data":[2,1,4,3]}]}}`,
+	"area":           `{"type":"area","title":"Area","data":{"xAxis":["Jan","Feb","Mar","Apr"],"series":[{"name":"a","data":[1,3,2,5]}]}}`,
+	"area stacked":   `{"type":"area","title":"Area","data":{"xAxis":["Jan","Feb","Mar","Apr"],"stacked":true,"series":[{"name":"a","data":[1,3,2,5]},{"name":"b","data":[2,1,4,3]}]}}`,
+	"bar":            `{"type":"bar","title":"Bar","data":{"xAxis":["Jan","Feb","Mar"],"series":[{"name":"a","data":[3,5,2]},{"name":"b","data":[4,1,6]}]}}`,
+	"bar stacked":    `{"type":"bar","title":"Bar","data":{"xAxis":["Jan","Feb","Mar"],"stacked":true,"series":[{"name":"a","data":[3,5,2]},{"name":"b","data":[4,1,6]}]}}`,
+	"bar horizontal": `{"type":"bar","title":"Bar","data":{"xAxis":["Jan","Feb","Mar"],"horizontal":true,"series":[{"name":"a","data":[3,5,2]}]}}`,
+	"pie":            `{"type":"pie","title":"Pie","data":{"data":[{"name":"a","value":3},{"name":"b","value":5},{"name":"c","value":2}]}}`,
+	"scatter":        `{"type":"scatter","title":"Scatter","data":{"series":[{"name":"a","data":[[1,2],[2,4],[3,1],[4,5]]}]}}`,
+}
+
+func renderTestChart(t *testing.T, raw, format string, scale float64) []byte {
+	t.Helper()
+	var config ChartConfig
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatal(err)
+	}
+	config.setDefaults()
+	provider := chart.SVG
+	if format == "png" {
+		provider = chart.PNG
+	}
+	var buf bytes.Buffer
+	if err := renderChart(&buf, config, scaled(provider, scale)); err != nil {
+		t.Fatalf("render %s at scale %g: %v", format, scale, err)
+	}
+	return buf.Bytes()
+}
+
+// inkedShare is the share of pixels that differ from the top left one, the
+// background.
+func inkedShare(t *testing.T, body []byte) float64 {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := img.Bounds()
+	bg := img.At(b.Min.X, b.Min.Y)
+	inked := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.At(x, y) != bg {
+				inked++
+			}
+		}
+	}
+	return float64(inked) / float64(b.Dx()*b.Dy())
+}
+
+func TestScaledCharts(t *testing.T) {
+	for name, raw := range scaleTestCharts {
+		t.Run(name, func(t *testing.T) {
+			for _, scale := range []float64{1, 1.5, 2} {
+				svg := string(renderTestChart(t, raw, "svg", scale))
+				if strings.Contains(svg, "-9223372036854775808") {
+					t.Errorf("SVG at scale %g has a coordinate computed from NaN", scale)
+				}
+			}
+
+			// The layout doesn't change with scale, so neither should how much
+			// of the canvas is drawn on.
+			want := inkedShare(t, renderTestChart(t, raw, "png", 1))
+			got := inkedShare(t, renderTestChart(t, raw, "png", 2))
+			if want == 0 || got < want/2 {
+				t.Errorf("PNG at scale 2 is %.2f%% drawn, at scale 1 %.2f%%", got*100, want*100)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	chartIDLength = 8
+	// chartIDAlphabet leaves out look-alikes such as 0/O and 1/l, since IDs
+	// get copied by hand between READMEs.
+	chartIDAlphabet = "23456789abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+var errChartNotFound = errors.New("chart not found")
+
+// storedChart is a saved config, looked up by its short ID. Only a hash of
+// its edit token is kept.
+type storedChart struct {
+	ID        string
+	TokenHash []byte
+	Config    []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// chartStore keeps saved charts in SQLite. Writes go through one mutex, so an
+// update reads and rewrites a chart without another one slipping in between.
+type chartStore struct {
+	db *sql.DB
+	mu sync.Mutex
+}
+
+func openChartStore(path string) (*chartStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range []string{
+		"PRAGMA journal_mode = WAL;",
+		"PRAGMA busy_timeout = 5000;",
+		`CREATE TABLE IF NOT EXISTS charts (
+			id         TEXT PRIMARY KEY,
+			token_hash BLOB NOT NULL,
+			config     BLOB NOT NULL,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	return &chartStore{db: db}, nil
+}
+
+func (s *chartStore) Close() error { return s.db.Close() }
+
+// Create saves config under a new ID and returns it with its edit token.
+func (s *chartStore) Create(ctx context.Context, config []byte) (id, token string, err error) {
+	token = newEditToken()
+	hash := sha256.Sum256([]byte(token))
+	now := time.Now().Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Eight characters from 56 make a collision unlikely, but not impossible.
+	for range 5 {
+		id = newChartID()
+		n, err := s.exec(ctx, "chart_insert",
+			"INSERT INTO charts (id, token_hash, config, created_at, updated_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING",
+			id, hash[:], config, now, now)
+		if err != nil {
+			return "", "", err
+		}
+		if n == 1 {
+			return id, token, nil
+		}
+	}
+	return "", "", errors.New("no free chart ID")
+}
+
+// Get loads the chart saved under id.
+func (s *chartStore) Get(ctx context.Context, id string) (*storedChart, error) {
+	done := metrics.TimeQuery("chart_get")
+	ctx, span := tracing.Start(ctx, "sqlite chart_get")
+	c := storedChart{ID: id}
+	var created, updated int64
+	err := s.db.QueryRowContext(ctx, "SELECT token_hash, config, created_at, updated_at FROM charts WHERE id = ?", id).
+		Scan(&c.TokenHash, &c.Config, &created, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		done(nil)
+		tracing.End(span, nil)
+		return nil, errChartNotFound
+	}
+	done(err)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	c.CreatedAt, c.UpdatedAt = time.Unix(created, 0), time.Unix(updated, 0)
+	return &c, nil
+}
+
+// Update replaces the config of chart id with what edit makes of the current
+// one, provided token is the chart's edit token, and returns the result.
+func (s *chartStore) Update(ctx context.Context, id, token string, edit func(config []byte) ([]byte, error)) (*storedChart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !c.checkToken(token) {
+		return nil, errBadToken
+	}
+	if c.Config, err = edit(c.Config); err != nil {
+		return nil, err
+	}
+	c.UpdatedAt = time.Now()
+	_, err = s.exec(ctx, "chart_update", "UPDATE charts SET config = ?, updated_at = ? WHERE id = ?", c.Config, c.UpdatedAt.Unix(), id)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+var errBadToken = errors.New("wrong edit token")
+
+func (c *storedChart) checkToken(token string) bool {
+	hash := sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(hash[:], c.TokenHash) == 1
+}
+
+// exec runs a statement, timed and traced as name, and returns how many rows
+// it changed.
+func (s *chartStore) exec(ctx context.Context, name, query string, args ...any) (int64, error) {
+	done := metrics.TimeQuery(name)
+	ctx, span := tracing.Start(ctx, "sqlite "+name)
+	res, err := s.db.ExecContext(ctx, query, args...)
+	var n int64
+	if err == nil {
+		n, err = res.RowsAffected()
+	}
+	done(err)
+	tracing.End(span, err)
+	return n, err
+}
+
+func newChartID() string {
+	b := make([]byte, chartIDLength)
+	for i := range b {
+		b[i] = chartIDAlphabet[randIntn(len(chartIDAlphabet))]
+	}
+	return string(b)
+}
+
+// randIntn draws from crypto/rand, rejecting bytes that would bias the result.
+func randIntn(n int) int {
+	limit := 256 - 256%n
+	var b [1]byte
+	for {
+		rand.Read(b[:])
+		if int(b[0]) < limit {
+			return int(b[0]) % n
+		}
+	}
+}
+
+func newEditToken() string {
+	var b [24]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+	"github.com/go-chi/chi/v5"
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+// storedChartCacheControl is shorter than for /chart URLs, whose config can
+// never change: a stored chart's data can, and image proxies such as GitHub's
+// should pick that up within minutes.
+const storedChartCacheControl = "public, max-age=300"
+
+// charts holds the saved charts served under /c/{id}.
+var charts *chartStore
+
+// chartInfo describes a saved chart. The edit token is only ever returned by
+// the request that created the chart.
+type chartInfo struct {
+	ID        string          `json:"id"`
+	Token     string          `json:"token,omitempty"`
+	SVG       string          `json:"svg"`
+	PNG       string          `json:"png"`
+	Config    json.RawMessage `json:"config"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+func newChartInfo(r *http.Request, c *storedChart) chartInfo {
+	return chartInfo{
+		ID:        c.ID,
+		SVG:       publicURL(r, "/c/"+c.ID+".svg"),
+		PNG:       publicURL(r, "/c/"+c.ID+".png"),
+		Config:    c.Config,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+// publicURL turns path into an absolute URL as the client sees it, behind
+// Caddy's /charts prefix when the request came through it.
+func publicURL(r *http.Request, path string) string {
+	scheme := "http"
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.Header.Get("X-Forwarded-Prefix") + path
+}
+
+// createChartHandler saves the chart config in the body of POST /charts and
+// answers with its ID, embed URLs and edit token.
+func createChartHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := readConfigBody(w, r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	var config ChartConfig
+	if err := json.Unmarshal(body, &config); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateChart(config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stored, err := json.Marshal(config)
+	if err != nil {
+		http.Error(w, "Error encoding chart: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	id, token, err := charts.Create(r.Context(), stored)
+	if err != nil {
+		logging.From(r.Context()).Error("chart insert failed", "err", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	logging.Add(r.Context(), "chart", id)
+
+	now := time.Now()
+	info := newChartInfo(r, &storedChart{ID: id, Config: stored, CreatedAt: now, UpdatedAt: now})
+	info.Token = token
+	writeChartInfo(w, http.StatusCreated, info)
+}
+
+// chartInfoHandler serves GET /charts/{id}: the saved config and embed URLs.
+func chartInfoHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := loadChart(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	writeChartInfo(w, http.StatusOK, newChartInfo(r, c))
+}
+
+// storedChartHandler serves GET /c/{id}.svg, .png or .json. Without an
+// extension the format is negotiated as for /chart.
+func storedChartHandler(w http.ResponseWriter, r *http.Request) {
+	id, format, _ := strings.Cut(chi.URLParam(r, "file"), ".")
+	c, ok := loadChart(w, r, id)
+	if !ok {
+		return
+	}
+	serveChart(w, r, c.Config, strings.ToLower(format), storedChartCacheControl)
+}
+
+// updateChartDataHandler serves PUT /charts/{id}/data. The body is a data
+// object as in the chart's config, which replaces the current data, or with
+// mode=append is added to it: labels and points go on the end of the series
+// with the same name, and pie slices replace those with the same name. keep=N
+// then trims every series to its last N points.
+func updateChartDataHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="charts"`)
+		http.Error(w, "Missing edit token. Send it as Authorization: Bearer {token}", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "replace"
+	}
+	if mode != "replace" && mode != "append" {
+		http.Error(w, "Invalid mode. Use 'replace' or 'append'.", http.StatusBadRequest)
+		return
+	}
+	keep := 0
+	if v := query.Get("keep"); v != "" {
+		var err error
+		if keep, err = strconv.Atoi(v); err != nil || keep < 1 {
+			http.Error(w, "Invalid keep. Use a positive number of points.", http.StatusBadRequest)
+			return
+		}
+	}
+	update, err := readConfigBody(w, r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	logging.Add(r.Context(), "chart", id, "mode", mode)
+	c, err := charts.Update(r.Context(), id, token, func(stored []byte) ([]byte, error) {
+		var config ChartConfig
+		err := json.Unmarshal(stored, &config)
+		if err != nil {
+			return nil, err
+		}
+		data := json.RawMessage(update)
+		if mode == "append" {
+			if data, err = appendChartData(config.Type, config.Data, data); err != nil {
+				return nil, err
+			}
+		}
+		if keep > 0 {
+			if data, err = trimChartData(config.Type, data, keep); err != nil {
+				return nil, err
+			}
+		}
+		config.Data = data
+		if err := validateChart(config); err != nil {
+			return nil, &requestError{http.StatusBadRequest, err.Error()}
+		}
+		merged, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		// Appending could otherwise grow a chart, and the cost of drawing it,
+		// without end.
+		if len(merged) > maxChartBodyBytes {
+			return nil, errChartTooLarge
+		}
+		return merged, nil
+	})
+	var reqErr *requestError
+	switch {
+	case errors.Is(err, errChartNotFound):
+		http.Error(w, "Chart not found", http.StatusNotFound)
+	case errors.Is(err, errBadToken):
+		http.Error(w, "Wrong edit token", http.StatusForbidden)
+	case errors.As(err, &reqErr):
+		http.Error(w, reqErr.message, reqErr.status)
+	case err != nil:
+		logging.From(r.Context()).Error("chart update failed", "chart", id, "err", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+	default:
+		writeChartInfo(w, http.StatusOK, newChartInfo(r, c))
+	}
+}
+
+// loadChart looks up chart id, answering the request itself when it can't.
+func loadChart(w http.ResponseWriter, r *http.Request, id string) (*storedChart, bool) {
+	c, err := charts.Get(r.Context(), id)
+	if errors.Is(err, errChartNotFound) {
+		http.Error(w, "Chart not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		logging.From(r.Context()).Error("chart lookup failed", "chart", id, "err", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return c, true
+}
+
+func writeChartInfo(w http.ResponseWriter, status int, info chartInfo) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(info)
+}
+
+// validateChart checks that config renders, so a broken chart is refused
+// when it is saved rather than served as an error to every README.
+func validateChart(config ChartConfig) error {
+	config.setDefaults()
+	format := strings.ToLower(config.Format)
+	switch format {
+	case "", "svg", "png", "json":
+	default:
+		return fmt.Errorf("Unsupported format: %s", config.Format)
+	}
+	if _, err := config.outputScale(format); err != nil {
+		return err
+	}
+	if err := renderChart(io.Discard, config, chart.SVG); err != nil {
+		if errors.Is(err, errUnsupportedType) {
+			return err
+		}
+		return fmt.Errorf("Invalid chart data: %w", err)
+	}
+	return nil
+}
+
+// appendChartData adds update to the data of a chart of chartType. Fields
+// other than the labels and series, such as stacked, are overwritten.
+func appendChartData(chartType string, current, update json.RawMessage) (json.RawMessage, error) {
+	cur, err := decodeObject(current)
+	if err != nil {
+		return nil, err
+	}
+	upd, err := decodeObject(update)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid data: " + err.Error()}
+	}
+	for key, value := range upd {
+		switch {
+		case key == "xAxis":
+			cur[key], err = appendList(cur[key], value)
+		case key == "series":
+			cur[key], err = mergeByName(cur[key], value, true)
+		case key == "data" && strings.EqualFold(chartType, "pie"):
+			cur[key], err = mergeByName(cur[key], value, false)
+		default:
+			cur[key] = value
+		}
+		if err != nil {
+			return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid data: %s: %v", key, err)}
+		}
+	}
+	return json.Marshal(cur)
+}
+
+// trimChartData keeps the last keep labels, points of each series, or pie
+// slices.
+func trimChartData(chartType string, data json.RawMessage, keep int) (json.RawMessage, error) {
+	obj, err := decodeObject(data)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid data: " + err.Error()}
+	}
+	if strings.EqualFold(chartType, "pie") {
+		if obj["data"], err = trimList(obj["data"], keep); err != nil {
+			return nil, &requestError{http.StatusBadRequest, "Invalid data: data: " + err.Error()}
+		}
+		return json.Marshal(obj)
+	}
+	if obj["xAxis"] != nil {
+		if obj["xAxis"], err = trimList(obj["xAxis"], keep); err != nil {
+			return nil, &requestError{http.StatusBadRequest, "Invalid data: xAxis: " + err.Error()}
+		}
+	}
+	var series []map[string]json.RawMessage
+	if len(obj["series"]) > 0 {
+		if err := json.Unmarshal(obj["series"], &series); err != nil {
+			return nil, &requestError{http.StatusBadRequest, "Invalid data: series: " + err.Error()}
+		}
+	}
+	for _, s := range series {
+		if s["data"], err = trimList(s["data"], keep); err != nil {
+			return nil, &requestError{http.StatusBadRequest, "Invalid data: series: " + err.Error()}
+		}
+	}
+	if series != nil {
+		obj["series"], _ = json.Marshal(series)
+	}
+	return json.Marshal(obj)
+}
+
+func decodeObject(raw json.RawMessage) (map[string]json.RawMessage, error) {
+	obj := make(map[string]json.RawMessage)
+	if len(raw) == 0 || string(raw) == "null" {
+		return obj, nil
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func appendList(current, update json.RawMessage) (json.RawMessage, error) {
+	var cur, upd []json.RawMessage
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &cur); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(update, &upd); err != nil {
+		return nil, err
+	}
+	return json.Marshal(append(cur, upd...))
+}
+
+func trimList(list json.RawMessage, keep int) (json.RawMessage, error) {
+	var items []json.RawMessage
+	if len(list) > 0 {
+		if err := json.Unmarshal(list, &items); err != nil {
+			return nil, err
+		}
+	}
+	if len(items) <= keep {
+		return list, nil
+	}
+	return json.Marshal(items[len(items)-keep:])
+}
+
+// mergeByName merges a list of series or pie slices into another, matching
+// them up by name. Unmatched items are added at the end. With appendData the
+// data of a matched series is appended to; its other fields, like every field
+// of a matched slice, are replaced.
+func mergeByName(current, update json.RawMessage, appendData bool) (json.RawMessage, error) {
+	var cur, upd []map[string]json.RawMessage
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &cur); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(update, &upd); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(cur))
+	for i, item := range cur {
+		index[itemName(item)] = i
+	}
+	for _, item := range upd {
+		name := itemName(item)
+		i, ok := index[name]
+		if !ok {
+			index[name] = len(cur)
+			cur = append(cur, item)
+			continue
+		}
+		for key, value := range item {
+			if key == "data" && appendData {
+				var err error
+				if cur[i][key], err = appendList(cur[i][key], value); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			cur[i][key] = value
+		}
+	}
+	return json.Marshal(cur)
+}
+
+func itemName(item map[string]json.RawMessage) string {
+	var name string
+	json.Unmarshal(item["name"], &name)
+	return name
+}
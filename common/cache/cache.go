@@ -0,0 +1,213 @@
+// Package cache is the response cache shared by the Go services. A Cache
+// wraps one of the interchangeable backends (in-memory, on-disk or
+// Redis-compatible) with a default TTL, a per-entry size limit and hit/miss
+// counters, so every service caches the same way whatever it stores.
+package cache
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMaxBytes bounds the memory and disk backends when Options and
+	// the cache URL leave it unset.
+	DefaultMaxBytes = 64 << 20
+	// DefaultMaxEntryBytes bounds a single value when there is no MaxBytes
+	// to derive it from, as with Redis.
+	DefaultMaxEntryBytes = 8 << 20
+)
+
+// Backend stores opaque values under string keys. A zero ttl means the entry
+// never expires. Backends that bound their size report it through Usage.
+type Backend interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	Usage() Usage
+	Close() error
+}
+
+// Usage is what a backend currently holds and has dropped to stay in bounds.
+// Backends that don't track it, like Redis, leave it zero.
+type Usage struct {
+	Entries   int
+	Bytes     int64
+	Evictions uint64
+	Expired   uint64
+}
+
+// Options configure a Cache. Zero values pick the defaults.
+type Options struct {
+	// Namespace keeps services apart when they share a directory or a Redis
+	// server.
+	Namespace string
+	// TTL is how long Set keeps an entry. Zero keeps it until evicted.
+	TTL time.Duration
+	// MaxBytes bounds the memory and disk backends, keys included.
+	MaxBytes int64
+	// MaxEntryBytes is the largest value Set stores; bigger ones are skipped
+	// rather than flushing the rest of the cache. Defaults to MaxBytes/8.
+	MaxEntryBytes int64
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = DefaultMaxBytes
+	}
+	if o.MaxEntryBytes <= 0 {
+		o.MaxEntryBytes = min(o.MaxBytes/8, DefaultMaxEntryBytes)
+	}
+	return o
+}
+
+// Stats are the counters of a Cache since it was opened.
+type Stats struct {
+	Namespace string `json:"namespace"`
+	Backend   string `json:"backend"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Sets      uint64 `json:"sets"`
+	Skipped   uint64 `json:"skipped"`
+	Errors    uint64 `json:"errors"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	Evictions uint64 `json:"evictions"`
+	Expired   uint64 `json:"expired"`
+}
+
+// Cache is safe for concurrent use. A nil *Cache is a cache that never hits,
+// so services can run with caching turned off without extra checks.
+type Cache struct {
+	backend Backend
+	kind    string
+	opts    Options
+
+	hits, misses, sets, skipped, errors atomic.Uint64
+}
+
+// New wraps backend, which kind names in Stats.
+func New(kind string, backend Backend, opts Options) *Cache {
+	return &Cache{backend: backend, kind: kind, opts: opts.withDefaults()}
+}
+
+// Get returns the value stored under key. Backend errors count as misses; a
+// broken cache must not break the request that consulted it.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	value, ok, err := c.backend.Get(key)
+	if err != nil {
+		c.fail("get", err)
+	}
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return value, true
+}
+
+// Set stores value under key for the default TTL. The cache keeps value as
+// is, so callers must not modify it afterwards.
+func (c *Cache) Set(key string, value []byte) {
+	if c == nil {
+		return
+	}
+	c.SetTTL(key, value, c.opts.TTL)
+}
+
+// SetTTL is Set with an explicit TTL, zero meaning no expiry.
+func (c *Cache) SetTTL(key string, value []byte, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	if int64(len(value)) > c.opts.MaxEntryBytes {
+		c.skipped.Add(1)
+		return
+	}
+	if err := c.backend.Set(key, value, ttl); err != nil {
+		c.fail("set", err)
+		return
+	}
+	c.sets.Add(1)
+}
+
+// Delete drops key if it is cached.
+func (c *Cache) Delete(key string) {
+	if c == nil {
+		return
+	}
+	if err := c.backend.Delete(key); err != nil {
+		c.fail("delete", err)
+	}
+}
+
+// Stats returns the counters along with the backend's usage.
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{Backend: "off"}
+	}
+	u := c.backend.Usage()
+	return Stats{
+		Namespace: c.opts.Namespace,
+		Backend:   c.kind,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Sets:      c.sets.Load(),
+		Skipped:   c.skipped.Load(),
+		Errors:    c.errors.Load(),
+		Entries:   u.Entries,
+		Bytes:     u.Bytes,
+		Evictions: u.Evictions,
+		Expired:   u.Expired,
+	}
+}
+
+// Close releases the backend's files or connections.
+func (c *Cache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.backend.Close()
+}
+
+// fail counts a backend error and logs the first few, so an unreachable
+// Redis doesn't flood the log on every request.
+func (c *Cache) fail(op string, err error) {
+	if n := c.errors.Add(1); n <= 10 || n%1000 == 0 {
+		slog.Warn("⚠️ cache backend failed", "backend", c.kind, "op", op, "errors", n, "err", err)
+	}
+}
+
+// GetJSON reads a value stored by SetJSON. An entry that no longer decodes
+// into T, say after the type changed, is dropped and reported as a miss.
+func GetJSON[T any](c *Cache, key string) (T, bool) {
+	var v T
+	raw, ok := c.Get(key)
+	if !ok {
+		return v, false
+	}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		c.Delete(key)
+		return v, false
+	}
+	return v, true
+}
+
+// SetJSON stores v encoded as JSON, for services caching structs rather than
+// rendered bytes.
+func SetJSON(c *Cache, key string, v any) {
+	if c == nil {
+		return
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		c.fail("encode", err)
+		return
+	}
+	c.Set(key, raw)
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskMagic starts every cache file, followed by the expiry in Unix
+// nanoseconds (zero for none) and the value.
+const (
+	diskMagic      = "uhc1"
+	diskHeaderSize = len(diskMagic) + 8
+)
+
+type diskEntry struct {
+	name string
+	size int64
+}
+
+// Disk keeps one file per entry under a directory, named by the SHA-256 of
+// the key and fanned out over 256 subdirectories. The index of what is on
+// disk lives in memory and is rebuilt from the files on open, so the cache
+// survives restarts; only one process should use a directory at a time.
+type Disk struct {
+	dir      string
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	order    *list.List // front is the most recently used
+	items    map[string]*list.Element
+	usage    Usage
+}
+
+// OpenDisk opens or creates a disk backend in dir holding at most maxBytes of
+// files. Leftovers over the limit are evicted oldest first.
+func OpenDisk(dir string, maxBytes int64) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cache dir: %w", err)
+	}
+	d := &Disk{dir: dir, maxBytes: maxBytes, order: list.New(), items: make(map[string]*list.Element)}
+
+	type found struct {
+		diskEntry
+		modified time.Time
+	}
+	var files []found
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(path, ".tmp") {
+			// A write that never got renamed into place.
+			os.Remove(path)
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !isEntryName(entry.Name()) {
+			return nil
+		}
+		files = append(files, found{diskEntry{entry.Name(), info.Size()}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cache dir: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modified.Before(files[j].modified) })
+	for _, f := range files {
+		e := f.diskEntry
+		d.items[e.name] = d.order.PushFront(&e)
+		d.bytes += e.size
+	}
+	d.mu.Lock()
+	d.evict()
+	d.mu.Unlock()
+	return d, nil
+}
+
+func isEntryName(name string) bool {
+	_, err := hex.DecodeString(name)
+	return err == nil && len(name) == sha256.Size*2
+}
+
+func (d *Disk) path(name string) string {
+	return filepath.Join(d.dir, name[:2], name)
+}
+
+func entryName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (d *Disk) Get(key string) ([]byte, bool, error) {
+	name := entryName(key)
+	d.mu.Lock()
+	_, ok := d.items[name]
+	d.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	raw, err := os.ReadFile(d.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Evicted or deleted while we weren't holding the lock.
+		d.drop(name)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(raw) < diskHeaderSize || string(raw[:len(diskMagic)]) != diskMagic {
+		d.Delete(key)
+		return nil, false, fmt.Errorf("corrupt cache file %s", name)
+	}
+	if exp := int64(binary.BigEndian.Uint64(raw[len(diskMagic):])); exp != 0 && time.Now().UnixNano() > exp {
+		d.Delete(key)
+		d.mu.Lock()
+		d.usage.Expired++
+		d.mu.Unlock()
+		return nil, false, nil
+	}
+
+	d.mu.Lock()
+	if el, ok := d.items[name]; ok {
+		d.order.MoveToFront(el)
+	}
+	d.mu.Unlock()
+	// Keep the recency across restarts, which order files by mtime.
+	now := time.Now()
+	os.Chtimes(d.path(name), now, now)
+	return raw[diskHeaderSize:], true, nil
+}
+
+func (d *Disk) Set(key string, value []byte, ttl time.Duration) error {
+	size := int64(diskHeaderSize + len(value))
+	if size > d.maxBytes {
+		return nil
+	}
+	name := entryName(key)
+	sub := filepath.Join(d.dir, name[:2])
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		return err
+	}
+
+	header := make([]byte, diskHeaderSize)
+	copy(header, diskMagic)
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(header[len(diskMagic):], uint64(time.Now().Add(ttl).UnixNano()))
+	}
+	// Write beside the target and rename, so readers never see half a file.
+	tmp, err := os.CreateTemp(sub, "*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(header, value...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), d.path(name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if el, ok := d.items[name]; ok {
+		d.bytes -= d.order.Remove(el).(*diskEntry).size
+	}
+	d.items[name] = d.order.PushFront(&diskEntry{name: name, size: size})
+	d.bytes += size
+	d.evict()
+	return nil
+}
+
+func (d *Disk) Delete(key string) error {
+	name := entryName(key)
+	d.drop(name)
+	if err := os.Remove(d.path(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (d *Disk) Usage() Usage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u := d.usage
+	u.Entries, u.Bytes = len(d.items), d.bytes
+	return u
+}
+
+func (d *Disk) Close() error { return nil }
+
+// drop forgets name without touching the file.
+func (d *Disk) drop(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if el, ok := d.items[name]; ok {
+		d.bytes -= d.order.Remove(el).(*diskEntry).size
+		delete(d.items, name)
+	}
+}
+
+// evict removes the least recently used files until the directory fits in
+// maxBytes. The caller holds d.mu.
+func (d *Disk) evict() {
+	for d.bytes > d.maxBytes {
+		e := d.order.Remove(d.order.Back()).(*diskEntry)
+		delete(d.items, e.name)
+		d.bytes -= e.size
+		d.usage.Evictions++
+		os.Remove(d.path(e.name))
+	}
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func diskSize(value []byte) int64 {
+	return int64(diskHeaderSize + len(value))
+}
+
+func openTestDisk(t *testing.T, dir string, maxBytes int64) *Disk {
+	t.Helper()
+	d, err := OpenDisk(dir, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func diskHas(t *testing.T, d *Disk, key string) bool {
+	t.Helper()
+	_, err := os.Stat(d.path(entryName(key)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestDiskGetSet(t *testing.T) {
+	d := openTestDisk(t, t.TempDir(), 1<<20)
+	if err := d.Set("k", []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := d.Get("k")
+	if err != nil || !ok || string(v) != "value" {
+		t.Fatalf("Get = %q, %v, %v", v, ok, err)
+	}
+	if u := d.Usage(); u.Entries != 1 || u.Bytes != diskSize([]byte("value")) {
+		t.Errorf("usage = %+v", u)
+	}
+
+	d.Delete("k")
+	if _, ok, _ := d.Get("k"); ok || diskHas(t, d, "k") {
+		t.Error("deleted entry still cached")
+	}
+	if u := d.Usage(); u.Entries != 0 || u.Bytes != 0 {
+		t.Errorf("usage after delete = %+v", u)
+	}
+}
+
+func TestDiskEviction(t *testing.T) {
+	value := make([]byte, 10)
+	d := openTestDisk(t, t.TempDir(), 2*diskSize(value))
+	d.Set("a", value, 0)
+	d.Set("b", value, 0)
+	// Reading a makes b the least recently used.
+	if _, ok, _ := d.Get("a"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+	d.Set("c", value, 0)
+
+	if _, ok, _ := d.Get("b"); ok || diskHas(t, d, "b") {
+		t.Error("b survived, want it evicted along with its file")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := d.Get(key); !ok {
+			t.Errorf("%s evicted, want b dropped instead", key)
+		}
+	}
+	if u := d.Usage(); u.Entries != 2 || u.Evictions != 1 {
+		t.Errorf("usage = %+v", u)
+	}
+
+	// A value that could never fit is skipped without flushing the rest.
+	d.Set("big", make([]byte, 4*len(value)), 0)
+	if diskHas(t, d, "big") || d.Usage().Entries != 2 {
+		t.Error("entry over maxBytes stored")
+	}
+}
+
+func TestDiskExpiry(t *testing.T) {
+	d := openTestDisk(t, t.TempDir(), 1<<20)
+	d.Set("short", []byte("x"), time.Nanosecond)
+	d.Set("long", []byte("x"), time.Hour)
+	time.Sleep(time.Millisecond)
+
+	if _, ok, _ := d.Get("short"); ok || diskHas(t, d, "short") {
+		t.Error("expired entry returned or left on disk")
+	}
+	if _, ok, _ := d.Get("long"); !ok {
+		t.Error("entry dropped before its TTL")
+	}
+	if u := d.Usage(); u.Expired != 1 || u.Entries != 1 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestDiskReload(t *testing.T) {
+	dir := t.TempDir()
+	value := make([]byte, 10)
+	d := openTestDisk(t, dir, 1<<20)
+	for _, key := range []string{"old", "mid", "new"} {
+		d.Set(key, value, 0)
+	}
+	d.Close()
+	// Recency survives a restart through mtimes; pin them so the order
+	// doesn't hang on the filesystem's timestamp resolution.
+	base := time.Now().Add(-time.Hour)
+	for i, key := range []string{"old", "mid", "new"} {
+		at := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(d.path(entryName(key)), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	leftover := filepath.Join(dir, "ab", "123.tmp")
+	os.MkdirAll(filepath.Dir(leftover), 0o755)
+	os.WriteFile(leftover, []byte("partial"), 0o644)
+
+	d = openTestDisk(t, dir, 1<<20)
+	if u := d.Usage(); u.Entries != 3 || u.Bytes != 3*diskSize(value) {
+		t.Errorf("usage after reload = %+v", u)
+	}
+	if v, ok, err := d.Get("mid"); err != nil || !ok || len(v) != len(value) {
+		t.Errorf("Get after reload = %v, %v", ok, err)
+	}
+	if _, err := os.Stat(leftover); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("unfinished write left in place")
+	}
+
+	// Reopening with less room evicts the least recently used first.
+	d = openTestDisk(t, dir, 2*diskSize(value))
+	if diskHas(t, d, "old") {
+		t.Error("oldest entry kept over the limit")
+	}
+	for _, key := range []string{"mid", "new"} {
+		if _, ok, _ := d.Get(key); !ok {
+			t.Errorf("%s evicted on reload, want the oldest dropped", key)
+		}
+	}
+	if u := d.Usage(); u.Entries != 2 || u.Evictions != 1 {
+		t.Errorf("usage after shrinking = %+v", u)
+	}
+}
+
+func TestDiskCorruptFile(t *testing.T) {
+	d := openTestDisk(t, t.TempDir(), 1<<20)
+	d.Set("k", []byte("value"), 0)
+	if err := os.WriteFile(d.path(entryName("k")), []byte("junk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := d.Get("k"); ok || err == nil {
+		t.Errorf("Get of a corrupt file = %v, %v, want an error", ok, err)
+	}
+	if diskHas(t, d, "k") || d.Usage().Entries != 0 {
+		t.Error("corrupt file kept")
+	}
+}
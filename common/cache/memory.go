@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entryOverhead approximates the bookkeeping of one entry (list element, map
+// slot, headers), so many tiny values still count against MaxBytes.
+const entryOverhead = 96
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key)+len(e.value)) + entryOverhead
+}
+
+// Memory is an LRU bounded by the total size of its entries rather than by
+// their count. Expired entries are dropped when they are next read or when
+// they reach the cold end of the list.
+type Memory struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	order    *list.List // front is the most recently used
+	items    map[string]*list.Element
+	usage    Usage
+}
+
+// NewMemory returns an empty in-memory backend holding at most maxBytes.
+func NewMemory(maxBytes int64) *Memory {
+	return &Memory{maxBytes: maxBytes, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (m *Memory) Get(key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		m.remove(el)
+		m.usage.Expired++
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	e := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	if e.size() > m.maxBytes {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	m.items[key] = m.order.PushFront(e)
+	m.bytes += e.size()
+	now := time.Now()
+	for m.bytes > m.maxBytes {
+		oldest := m.order.Back()
+		if exp := oldest.Value.(*memoryEntry).expires; !exp.IsZero() && now.After(exp) {
+			m.usage.Expired++
+		} else {
+			m.usage.Evictions++
+		}
+		m.remove(oldest)
+	}
+	return nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+func (m *Memory) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.usage
+	u.Entries, u.Bytes = len(m.items), m.bytes
+	return u
+}
+
+func (m *Memory) Close() error { return nil }
+
+func (m *Memory) remove(el *list.Element) {
+	e := m.order.Remove(el).(*memoryEntry)
+	delete(m.items, e.key)
+	m.bytes -= e.size()
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func memorySize(key string, value []byte) int64 {
+	return int64(len(key)+len(value)) + entryOverhead
+}
+
+func TestMemoryByteAccounting(t *testing.T) {
+	m := NewMemory(1 << 20)
+	m.Set("a", []byte("hello"), 0)
+	m.Set("bb", make([]byte, 100), 0)
+	if u := m.Usage(); u.Entries != 2 || u.Bytes != memorySize("a", []byte("hello"))+memorySize("bb", make([]byte, 100)) {
+		t.Fatalf("usage after two sets = %+v", u)
+	}
+
+	// Replacing a value counts the new size only.
+	m.Set("a", []byte("hi"), 0)
+	if u := m.Usage(); u.Entries != 2 || u.Bytes != memorySize("a", []byte("hi"))+memorySize("bb", make([]byte, 100)) {
+		t.Fatalf("usage after replace = %+v", u)
+	}
+
+	m.Delete("bb")
+	m.Delete("missing")
+	if u := m.Usage(); u.Entries != 1 || u.Bytes != memorySize("a", []byte("hi")) {
+		t.Fatalf("usage after delete = %+v", u)
+	}
+}
+
+func TestMemoryLRU(t *testing.T) {
+	value := make([]byte, 10)
+	m := NewMemory(2 * memorySize("a", value))
+	m.Set("a", value, 0)
+	m.Set("b", value, 0)
+	// Reading a makes b the least recently used.
+	if _, ok, _ := m.Get("a"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+	m.Set("c", value, 0)
+
+	if _, ok, _ := m.Get("b"); ok {
+		t.Error("b survived, want it evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := m.Get(key); !ok {
+			t.Errorf("%s evicted, want b dropped instead", key)
+		}
+	}
+	if u := m.Usage(); u.Entries != 2 || u.Evictions != 1 || u.Bytes > 2*memorySize("a", value) {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	value := make([]byte, 10)
+	m := NewMemory(2 * memorySize("a", value))
+	m.Set("a", value, time.Nanosecond)
+	m.Set("b", value, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if _, ok, _ := m.Get("a"); ok {
+		t.Error("expired entry returned")
+	}
+	// b expired at the cold end, so pushing it out isn't an eviction.
+	m.Set("c", value, 0)
+	m.Set("d", value, 0)
+	if u := m.Usage(); u.Expired != 2 || u.Evictions != 0 {
+		t.Errorf("usage = %+v, want 2 expired and no evictions", u)
+	}
+}
+
+func TestMemoryOversizedEntry(t *testing.T) {
+	m := NewMemory(200)
+	m.Set("small", []byte("x"), 0)
+	m.Set("big", make([]byte, 200), 0)
+	if _, ok, _ := m.Get("big"); ok {
+		t.Error("entry over maxBytes stored")
+	}
+	if _, ok, _ := m.Get("small"); !ok {
+		t.Error("entry over maxBytes flushed the rest of the cache")
+	}
+}
+
+func TestCacheMaxEntryBytes(t *testing.T) {
+	c := New("memory", NewMemory(1<<20), Options{MaxEntryBytes: 16})
+	c.Set("fits", make([]byte, 16))
+	c.Set("too big", make([]byte, 17))
+
+	if _, ok := c.Get("too big"); ok {
+		t.Error("value over MaxEntryBytes stored")
+	}
+	if v, ok := c.Get("fits"); !ok || !bytes.Equal(v, make([]byte, 16)) {
+		t.Error("value at MaxEntryBytes not stored")
+	}
+	if s := c.Stats(); s.Sets != 1 || s.Skipped != 1 || s.Entries != 1 {
+		t.Errorf("stats = %+v, want one set and one skipped", s)
+	}
+}
+
+func TestCacheMaxEntryBytesDefault(t *testing.T) {
+	o := Options{MaxBytes: 800}.withDefaults()
+	if o.MaxEntryBytes != 100 {
+		t.Errorf("MaxEntryBytes = %d, want MaxBytes/8", o.MaxEntryBytes)
+	}
+	if o := (Options{}).withDefaults(); o.MaxEntryBytes != DefaultMaxEntryBytes {
+		t.Errorf("MaxEntryBytes = %d, want %d", o.MaxEntryBytes, DefaultMaxEntryBytes)
+	}
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisIdleConns = 8
+	redisTimeout   = time.Second
+)
+
+// redisError is an error reply from the server, as opposed to a broken
+// connection; the connection stays usable after one.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Redis talks RESP to anything that speaks it (Redis, Valkey, KeyDB,
+// Dragonfly) using GET, SET with PX and DEL only. The server enforces its own
+// memory limit, so Usage stays zero. Connections are dialled on demand, so a
+// server that is down at startup only costs misses until it comes back.
+type Redis struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config
+	timeout  time.Duration
+	prefix   string
+	idle     chan *redisConn
+}
+
+// OpenRedis parses a redis:// or rediss:// URL of the form
+// redis://[user:password@]host[:port][/db][?timeout=1s]. Keys are prefixed
+// with prefix so services can share a server.
+func OpenRedis(rawURL, prefix string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis url: %w", err)
+	}
+	r := &Redis{
+		addr:    u.Host,
+		timeout: redisTimeout,
+		prefix:  prefix,
+		idle:    make(chan *redisConn, redisIdleConns),
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("redis url: missing host")
+	}
+	if u.Scheme == "rediss" {
+		r.tls = &tls.Config{ServerName: u.Hostname()}
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil || r.db < 0 {
+			return nil, fmt.Errorf("redis url: invalid db %q", db)
+		}
+	}
+	if t := u.Query().Get("timeout"); t != "" {
+		if r.timeout, err = time.ParseDuration(t); err != nil || r.timeout <= 0 {
+			return nil, fmt.Errorf("redis url: invalid timeout %q", t)
+		}
+	}
+	return r, nil
+}
+
+func (r *Redis) Get(key string) ([]byte, bool, error) {
+	reply, err := r.do("GET", r.prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	args := []any{"SET", r.prefix + key, value}
+	if ttl > 0 {
+		args = append(args, "PX", max(ttl.Milliseconds(), 1))
+	}
+	_, err := r.do(args...)
+	return err
+}
+
+func (r *Redis) Delete(key string) error {
+	_, err := r.do("DEL", r.prefix+key)
+	return err
+}
+
+func (r *Redis) Usage() Usage { return Usage{} }
+
+func (r *Redis) Close() error {
+	for {
+		select {
+		case c := <-r.idle:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends one command and reads its reply. Connections that fail mid-way
+// are closed rather than returned to the pool, since their stream may be out
+// of step.
+func (r *Redis) do(args ...any) (any, error) {
+	c, err := r.conn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(r.timeout, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.Close()
+		return nil, err
+	}
+	select {
+	case r.idle <- c:
+	default:
+		c.Close()
+	}
+	return reply, err
+}
+
+func (r *Redis) conn() (*redisConn, error) {
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: r.timeout}
+	var nc net.Conn
+	var err error
+	if r.tls != nil {
+		nc, err = tls.DialWithDialer(dialer, "tcp", r.addr, r.tls)
+	} else {
+		nc, err = dialer.Dial("tcp", r.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+
+	if r.password != "" {
+		auth := []any{"AUTH", r.password}
+		if r.username != "" {
+			auth = []any{"AUTH", r.username, r.password}
+		}
+		if _, err := c.roundTrip(r.timeout, auth...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := c.roundTrip(r.timeout, "SELECT", r.db); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// roundTrip writes args as a RESP array of bulk strings and reads the reply.
+func (c *redisConn) roundTrip(timeout time.Duration, args ...any) (any, error) {
+	c.SetDeadline(time.Now().Add(timeout))
+	w := bufio.NewWriter(c.Conn)
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case []byte:
+			b = v
+		case string:
+			b = []byte(v)
+		default:
+			b = fmt.Append(nil, v)
+		}
+		fmt.Fprintf(w, "$%d\r\n", len(b))
+		w.Write(b)
+		w.WriteString("\r\n")
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply decodes one RESP2 reply: a string, an error, an integer, a bulk
+// string (nil when absent) or an array of those.
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, rest := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", rest)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("redis: malformed array length %q", rest)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
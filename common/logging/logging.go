@@ -0,0 +1,196 @@
+// Package logging sets up the structured logs shared by the services: one
+// JSON object per line from log/slog, tagged with the service name, and one
+// line per request carrying its request ID. The standard log package is
+// routed through the same handler, so older log.Printf calls come out as
+// JSON too.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID. Caddy sets it on the way in, and
+// the service echoes it on the response so a client can quote it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxQueryLog bounds how much of the query string a request line carries;
+// chart configs can run to kilobytes.
+const maxQueryLog = 512
+
+// Setup makes a handler writing to stderr the default for slog and the log
+// package. format is "json" or "text", the latter for reading logs in a
+// terminal.
+func Setup(service, format string, level slog.Level) error {
+	h, err := newHandler(os.Stderr, format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h).With("service", service))
+	return nil
+}
+
+func newHandler(w io.Writer, format string, level slog.Level) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, want json or text", format)
+}
+
+type ctxKey struct{}
+
+// request is what the middleware knows about a request in flight. Handlers
+// reach it through the context to log with its ID or add to its log line.
+type request struct {
+	id     string
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	attrs []any
+}
+
+// From returns the logger for the request ctx belongs to, which tags every
+// line with the request ID, or the default logger outside a request.
+func From(ctx context.Context) *slog.Logger {
+	if req, ok := ctx.Value(ctxKey{}).(*request); ok {
+		return req.logger
+	}
+	return slog.Default()
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(ctxKey{}).(*request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// Add puts key-value pairs, as for slog.Logger.Info, on the request's log
+// line, e.g. the user a dashboard was rendered for.
+func Add(ctx context.Context, args ...any) {
+	if req, ok := ctx.Value(ctxKey{}).(*request); ok {
+		req.mu.Lock()
+		req.attrs = append(req.attrs, args...)
+		req.mu.Unlock()
+	}
+}
+
+// Middleware assigns each request an ID, tags its lines with the trace ID
+// when tracing is on, recovers panics into a logged 500, and logs one line
+// per request once it is done: method, path, chi route and URL params, query,
+// status, bytes, duration, and the X-Cache status when the handler set one.
+// Server errors log at error level, the rest at info.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validID(id) {
+			id = newID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		logger := slog.Default().With("request_id", id)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+		req := &request{id: id, logger: logger}
+		r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, req))
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				req.logger.Error("panic serving request", "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+				if ww.Status() == 0 {
+					http.Error(ww, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}
+			req.log(r, ww, time.Since(start))
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+func (req *request) log(r *http.Request, ww middleware.WrapResponseWriter, elapsed time.Duration) {
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	args := []any{
+		"method", r.Method,
+		"path", r.URL.Path,
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if route := rctx.RoutePattern(); route != "" {
+			args = append(args, "route", route)
+		}
+		if keys := rctx.URLParams.Keys; len(keys) > 0 {
+			params := make([]any, 0, 2*len(keys))
+			for i, k := range keys {
+				params = append(params, k, rctx.URLParams.Values[i])
+			}
+			args = append(args, slog.Group("params", params...))
+		}
+	}
+	if q := r.URL.RawQuery; q != "" {
+		if len(q) > maxQueryLog {
+			q = q[:maxQueryLog] + "…"
+		}
+		args = append(args, "query", q)
+	}
+	args = append(args,
+		"status", status,
+		"bytes", ww.BytesWritten(),
+		"duration_ms", float64(elapsed.Microseconds())/1000,
+	)
+	if c := ww.Header().Get("X-Cache"); c != "" {
+		args = append(args, "cache", c)
+	}
+	args = append(args, "remote", r.RemoteAddr)
+	req.mu.Lock()
+	args = append(args, req.attrs...)
+	req.mu.Unlock()
+
+	level := slog.LevelInfo
+	if status >= 500 {
+		level = slog.LevelError
+	}
+	req.logger.Log(r.Context(), level, "request", args...)
+}
+
+// validID accepts IDs of up to 128 URL-safe characters, which covers Caddy's
+// UUIDs and common tracing formats while keeping log injection out.
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return strings.IndexFunc(id, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c))
+	}) < 0
+}
+
+func newID() string {
+	var b [12]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"github.com/Kishan-Agarwal-28/utils_hub/common/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var cacheLabels = []string{"cache", "backend"}
+
+var (
+	cacheRequestsDesc = prometheus.NewDesc("cache_requests_total",
+		"Cache lookups by result, hit or miss.", append(cacheLabels, "result"), nil)
+	cacheSetsDesc = prometheus.NewDesc("cache_sets_total",
+		"Values stored in the cache.", cacheLabels, nil)
+	cacheSkippedDesc = prometheus.NewDesc("cache_skipped_total",
+		"Values not stored for being over the per-entry size limit.", cacheLabels, nil)
+	cacheErrorsDesc = prometheus.NewDesc("cache_errors_total",
+		"Backend errors, each served as a miss.", cacheLabels, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("cache_evictions_total",
+		"Entries dropped to stay within the size bound.", cacheLabels, nil)
+	cacheExpiredDesc = prometheus.NewDesc("cache_expired_total",
+		"Entries dropped after their TTL.", cacheLabels, nil)
+	cacheEntriesDesc = prometheus.NewDesc("cache_entries",
+		"Entries currently cached (memory and disk backends).", cacheLabels, nil)
+	cacheBytesDesc = prometheus.NewDesc("cache_bytes",
+		"Bytes currently cached, keys included (memory and disk backends).", cacheLabels, nil)
+)
+
+// RegisterCache exports c's Stats, read at scrape time. A nil cache, as
+// with CACHE_URL=off, registers nothing.
+func RegisterCache(c *cache.Cache) {
+	if c == nil {
+		return
+	}
+	prometheus.MustRegister(cacheCollector{c})
+}
+
+type cacheCollector struct{ c *cache.Cache }
+
+func (cc cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{cacheRequestsDesc, cacheSetsDesc, cacheSkippedDesc, cacheErrorsDesc,
+		cacheEvictionsDesc, cacheExpiredDesc, cacheEntriesDesc, cacheBytesDesc} {
+		ch <- d
+	}
+}
+
+func (cc cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := cc.c.Stats()
+	counter := func(d *prometheus.Desc, v uint64, extra ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v), append([]string{s.Namespace, s.Backend}, extra...)...)
+	}
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, s.Namespace, s.Backend)
+	}
+	counter(cacheRequestsDesc, s.Hits, "hit")
+	counter(cacheRequestsDesc, s.Misses, "miss")
+	counter(cacheSetsDesc, s.Sets)
+	counter(cacheSkippedDesc, s.Skipped)
+	counter(cacheErrorsDesc, s.Errors)
+	counter(cacheEvictionsDesc, s.Evictions)
+	counter(cacheExpiredDesc, s.Expired)
+	gauge(cacheEntriesDesc, float64(s.Entries))
+	gauge(cacheBytesDesc, float64(s.Bytes))
+}
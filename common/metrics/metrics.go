@@ -0,0 +1,125 @@
+// Package metrics exposes Prometheus metrics for the services: request
+// latency and status per route, cache counters, SQLite query durations and
+// upstream API outcomes. Everything registers with the default registry, so
+// Handler also serves the Go runtime and process metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "http_request_duration_seconds",
+		Help: "Time to serve HTTP requests by route pattern and method.",
+		// Cached avatars take microseconds, dashboards tens of seconds.
+		Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time spent on SQLite queries, including reading their rows.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"query", "outcome"})
+
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_requests_total",
+		Help: "Calls to upstream APIs by outcome: a status class such as 2xx, or error when no response arrived.",
+	}, []string{"upstream", "outcome"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Time until upstream APIs responded with headers.",
+		Buckets: []float64{.025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"upstream"})
+)
+
+// Handler serves every registered metric in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records the duration and status of each request, labelled
+// with the chi route pattern rather than the path so that /avatar/{hash}
+// is one series and not one per hash. Requests no route matched are
+// labelled "unmatched".
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		defer func() {
+			code := ww.Status()
+			if rec := recover(); rec != nil {
+				// The recoverer further out turns this into a 500.
+				code = http.StatusInternalServerError
+				defer panic(rec)
+			}
+			if code == 0 {
+				code = http.StatusOK
+			}
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			requests.WithLabelValues(route, r.Method, strconv.Itoa(code)).Inc()
+			requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// TimeQuery starts timing the SQLite query name. Call the returned function
+// with the query's error once its rows have been read.
+func TimeQuery(name string) func(error) {
+	start := time.Now()
+	return func(err error) {
+		queryDuration.WithLabelValues(name, outcome(err)).Observe(time.Since(start).Seconds())
+	}
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// Transport wraps base (http.DefaultTransport when nil) so every call
+// through it is counted under upstream. Name upstreams by what they are, not
+// by host, since some hosts come from user input.
+func Transport(upstream string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper{upstream: upstream, base: base}
+}
+
+type roundTripper struct {
+	upstream string
+	base     http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	upstreamDuration.WithLabelValues(t.upstream).Observe(time.Since(start).Seconds())
+	result := "error"
+	if err == nil {
+		result = strconv.Itoa(resp.StatusCode/100) + "xx"
+	}
+	upstreamRequests.WithLabelValues(t.upstream, result).Inc()
+	return resp, err
+}
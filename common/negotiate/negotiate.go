@@ -0,0 +1,79 @@
+// Package negotiate picks the output format of a request from its `format`
+// query parameter or, failing that, its Accept header, so every service that
+// renders more than one format answers content negotiation the same way.
+package negotiate
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Offer pairs a `format` value with the media type it is served as.
+type Offer struct {
+	Format    string
+	MediaType string
+}
+
+// Format resolves the output format for a request. An explicit `format` query
+// parameter always wins; otherwise the Accept header is matched against
+// offers, which are listed in the server's order of preference. Requests
+// without a usable Accept header get the first offer.
+func Format(r *http.Request, offers []Offer) string {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		return format
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0].Format
+	}
+
+	type mediaRange struct {
+		typ, subtype string
+		q            float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(fields[0])), "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "q" {
+				if f, err := strconv.ParseFloat(value, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		ranges = append(ranges, mediaRange{typ, subtype, q})
+	}
+
+	best, bestQ := offers[0].Format, 0.0
+	for _, offer := range offers {
+		typ, subtype, _ := strings.Cut(offer.MediaType, "/")
+		// The most specific matching range decides the offer's quality.
+		q, specificity := 0.0, 0
+		for _, mr := range ranges {
+			s := 0
+			switch {
+			case mr.typ == typ && mr.subtype == subtype:
+				s = 3
+			case mr.typ == typ && mr.subtype == "*":
+				s = 2
+			case mr.typ == "*" && mr.subtype == "*":
+				s = 1
+			}
+			if s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer.Format, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listValue is a comma-separated flag. "none" clears the list.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	*l = nil
+	if strings.EqualFold(s, "none") {
+		return nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// rateValue is a rate limit flag written as requests/window, e.g. 100/1m.
+// A bare count keeps the current window, and 0 disables limiting.
+type rateValue struct {
+	limit  *int
+	window *time.Duration
+}
+
+func (r *rateValue) String() string {
+	if r.limit == nil {
+		return ""
+	}
+	if *r.limit <= 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", *r.limit, *r.window)
+}
+
+func (r *rateValue) Set(s string) error {
+	count, window, hasWindow := strings.Cut(strings.TrimSpace(s), "/")
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return fmt.Errorf("want requests/window such as 100/1m, got %q", s)
+	}
+	if hasWindow {
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("want requests/window such as 100/1m, got %q", s)
+		}
+		*r.window = d
+	}
+	*r.limit = n
+	return nil
+}
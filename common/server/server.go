@@ -0,0 +1,303 @@
+// Package server builds the chi router and http.Server every service runs
+// on, so middleware, limits and timeouts are set in one place instead of
+// drifting between copies of main.go.
+package server
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+	"github.com/go-chi/httprate"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
+)
+
+// Config describes a service's server. Start from Defaults and change what
+// the service needs; zero values turn a feature off rather than picking a
+// default.
+type Config struct {
+	// Name identifies the service in logs and its command line usage.
+	Name string
+	Port string
+
+	// LogFormat is "json" or "text"; see package logging.
+	LogFormat string
+	LogLevel  slog.Level
+
+	// Heartbeat is answered with 200 "." ahead of rate limiting and CORS, for
+	// load balancer checks. Empty leaves the path to the service.
+	Heartbeat string
+	// Metrics serves the Prometheus metrics (see package metrics), also ahead
+	// of rate limiting, and turns on per-route request metrics. Caddy keeps
+	// it off the public port. Empty disables both.
+	Metrics string
+	// Compress is the gzip/deflate level for compressible responses.
+	Compress int
+	// RealIP takes the client address from X-Real-IP, which Caddy sets to
+	// the address it was connected from, so logs and rate limits see clients
+	// rather than the proxy. Only turn it on behind a proxy that overwrites
+	// the header; otherwise clients can pick their own address.
+	RealIP bool
+
+	// RateLimit is the number of requests a client IP may make per
+	// RateWindow.
+	RateLimit  int
+	RateWindow time.Duration
+
+	CORSOrigins []string
+	CORSMethods []string
+	// CORSHeaders lists the request headers browsers may send cross-origin.
+	// Empty allows the simple ones, Accept and Content-Type.
+	CORSHeaders []string
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is how long in-flight requests get to finish after
+	// SIGTERM or SIGINT before their connections are closed. It stays under
+	// the 10s Docker waits before SIGKILL.
+	ShutdownTimeout time.Duration
+}
+
+// Defaults is the configuration shared by the services: rate limited to 100
+// requests a minute per client, open CORS, compressed, with /health and
+// /metrics.
+func Defaults(name, port string) Config {
+	return Config{
+		Name:              name,
+		Port:              port,
+		LogFormat:         "json",
+		LogLevel:          slog.LevelInfo,
+		Heartbeat:         "/health",
+		Metrics:           "/metrics",
+		Compress:          5,
+		RealIP:            true,
+		RateLimit:         100,
+		RateWindow:        time.Minute,
+		CORSOrigins:       []string{"*"},
+		CORSMethods:       []string{"GET", "POST", "OPTIONS"},
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   8 * time.Second,
+	}
+}
+
+// Server is a chi router with the shared middleware installed. Register
+// routes on it, then call Run.
+type Server struct {
+	chi.Router
+	cfg     Config
+	closers []func() error
+}
+
+// New applies environment variables and command line flags on top of cfg
+// (see Config.Load), sets up logging and tracing, and installs the
+// middleware stack. A bad
+// value in either stops the process with a usage message. Call it first in
+// main, so that startup logs are structured too.
+func New(cfg Config) *Server {
+	cfg.Load(os.Args[1:])
+	if err := logging.Setup(cfg.Name, cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatalf("❌ Invalid LOG_FORMAT: %v", err)
+	}
+	flushTraces, err := tracing.Setup(cfg.Name)
+	if err != nil {
+		slog.Error("❌ Invalid tracing settings", "err", err)
+		os.Exit(1)
+	}
+
+	r := chi.NewRouter()
+	if cfg.RealIP {
+		r.Use(realIP)
+	}
+	// Health checks and scrapes come every few seconds; keep them out of
+	// the request log.
+	if cfg.Heartbeat != "" {
+		r.Use(middleware.Heartbeat(cfg.Heartbeat))
+	}
+	if cfg.Metrics != "" {
+		r.Use(serve(cfg.Metrics, metrics.Handler()))
+	}
+	if flushTraces != nil {
+		r.Use(tracing.Middleware)
+	}
+	r.Use(logging.Middleware)
+	if cfg.Metrics != "" {
+		r.Use(metrics.Middleware)
+	}
+	if cfg.Compress > 0 {
+		r.Use(middleware.Compress(cfg.Compress))
+	}
+	if cfg.RateLimit > 0 {
+		r.Use(httprate.Limit(
+			cfg.RateLimit,
+			cfg.RateWindow,
+			httprate.WithKeyFuncs(httprate.KeyByIP),
+		))
+	}
+	if len(cfg.CORSOrigins) > 0 {
+		r.Use(cors.Handler(cors.Options{
+			AllowedOrigins: cfg.CORSOrigins,
+			AllowedMethods: cfg.CORSMethods,
+			AllowedHeaders: cfg.CORSHeaders,
+		}))
+	}
+	s := &Server{Router: r, cfg: cfg}
+	if flushTraces != nil {
+		// Registered first, so it runs last and sees the shutdown's spans.
+		s.OnShutdown(flushTraces)
+	}
+	return s
+}
+
+// serve answers GET requests for path with h before the rest of the stack,
+// the way middleware.Heartbeat does.
+func serve(path string, h http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (r.Method == http.MethodGet || r.Method == http.MethodHead) && r.URL.Path == path {
+				h.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Config returns the configuration in effect, after environment and flags.
+func (s *Server) Config() Config {
+	return s.cfg
+}
+
+// realIP replaces the remote address with X-Real-IP. Unlike chi's
+// middleware.RealIP it ignores True-Client-IP and X-Forwarded-For, which Caddy
+// passes through as the client sent them: trusting those would let a client
+// rotate addresses and get a fresh rate limit on every request.
+func realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			r.RemoteAddr = ip.String()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OnShutdown registers fn to run when Run returns, once in-flight requests
+// have drained, so handlers never see a closed database. Hooks run in reverse
+// order of registration, like defers.
+func (s *Server) OnShutdown(fn func() error) {
+	s.closers = append(s.closers, fn)
+}
+
+func (s *Server) close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if err := s.closers[i](); err != nil {
+			slog.Warn("⚠️ shutdown hook failed", "err", err)
+		}
+	}
+}
+
+// Run serves until SIGTERM or SIGINT, then stops accepting connections and
+// waits up to ShutdownTimeout for in-flight requests before running the
+// OnShutdown hooks. It returns nil after a clean shutdown, and an error when
+// the port can't be bound or requests had to be cut off, so the process can
+// exit non-zero.
+func (s *Server) Run() error {
+	defer s.close()
+
+	srv := &http.Server{
+		Addr:              ":" + s.cfg.Port,
+		Handler:           s.Router,
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		ReadTimeout:       s.cfg.ReadTimeout,
+		WriteTimeout:      s.cfg.WriteTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Bind before logging, so a port that is taken fails here and not after
+	// we've claimed to be running.
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.cfg.Name, err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	slog.Info("🚀 running", "port", s.cfg.Port)
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("%s: %w", s.cfg.Name, err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("🛑 shutting down, draining requests", "timeout", s.cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("%s: requests still running after %s: %w", s.cfg.Name, s.cfg.ShutdownTimeout, err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("👋 stopped")
+	return nil
+}
+
+// Load overrides cfg with environment variables, then with flags parsed
+// from args:
+//
+//	PORT              -port               8000
+//	CORS_ORIGINS      -cors-origins       https://a.example,https://b.example (none disables CORS)
+//	RATE_LIMIT        -rate-limit         100/1m (0 disables)
+//	READ_TIMEOUT      -read-timeout       15s
+//	WRITE_TIMEOUT     -write-timeout      30s
+//	IDLE_TIMEOUT      -idle-timeout       2m
+//	SHUTDOWN_TIMEOUT  -shutdown-timeout   8s
+//	LOG_LEVEL         -log-level          debug, info, warn or error
+//	LOG_FORMAT        -log-format         json or text
+func (cfg *Config) Load(args []string) {
+	fs := flag.NewFlagSet(cfg.Name, flag.ExitOnError)
+	fs.StringVar(&cfg.Port, "port", cfg.Port, "port to listen on")
+	fs.Var((*listValue)(&cfg.CORSOrigins), "cors-origins", "comma-separated allowed CORS origins, none to disable CORS")
+	fs.Var(&rateValue{&cfg.RateLimit, &cfg.RateWindow}, "rate-limit", "requests per client IP as N/duration, 0 to disable")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", cfg.ReadTimeout, "maximum time to read a request")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "maximum time to write a response")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout, "how long idle keep-alive connections stay open")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "how long to drain requests on shutdown")
+	fs.TextVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "least severe level logged: debug, info, warn or error")
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log output, json or text")
+
+	fs.VisitAll(func(f *flag.Flag) {
+		env := strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v := os.Getenv(env); v != "" {
+			if err := fs.Set(f.Name, v); err != nil {
+				log.Fatalf("❌ Invalid %s=%q: %v", env, v, err)
+			}
+		}
+	})
+	fs.Parse(args)
+}
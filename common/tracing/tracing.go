@@ -0,0 +1,132 @@
+// Package tracing sets up OpenTelemetry tracing for the services. It is off
+// unless the standard OTEL_* environment variables ask for an exporter, in
+// which case every request gets a span named after its chi route, and
+// services add child spans around upstream calls, queries and render steps
+// with Start and End.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
+
+// flushTimeout bounds how long shutdown waits to export the last spans.
+const flushTimeout = 2 * time.Second
+
+// Setup installs a tracer provider for service when OTEL_TRACES_EXPORTER
+// names one:
+//
+//	otlp     OTLP over HTTP, to OTEL_EXPORTER_OTLP_ENDPOINT (http://localhost:4318 by default)
+//	console  one JSON object per span on stdout
+//	none     no tracing
+//
+// Without OTEL_TRACES_EXPORTER, setting OTEL_EXPORTER_OTLP_ENDPOINT (or its
+// _TRACES_ variant) picks otlp, and otherwise tracing stays off. The other
+// OTEL_* variables, such as OTEL_SERVICE_NAME and OTEL_TRACES_SAMPLER, apply
+// as usual. The returned function flushes pending spans; it is nil when
+// tracing is off.
+func Setup(service string) (shutdown func() error, err error) {
+	exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	if exporter == "" && (os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "") {
+		exporter = "otlp"
+	}
+
+	var exp sdktrace.SpanExporter
+	switch exporter {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		if p := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" && p != "http/protobuf" {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL=%q is not supported, use http/protobuf", p)
+		}
+		exp, err = otlptracehttp.New(context.Background())
+	case "console":
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, want otlp, console or none", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win over the defaults.
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", service)))
+	if err == nil {
+		res, err = resource.Merge(res, resource.Environment())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	// Continue traces started upstream, e.g. by Caddy's tracing directive.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		return tp.Shutdown(ctx)
+	}, nil
+}
+
+// Middleware starts a server span for each request, continuing the caller's
+// trace when it sent a traceparent header. Once chi has routed the request
+// the span is renamed after the route pattern, e.g. "GET /avatar/{hash}",
+// so traces group the way metrics do.
+func Middleware(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(attribute.String("http.route", rctx.RoutePattern()))
+		}
+	})
+	return otelhttp.NewHandler(named, "request",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method }))
+}
+
+// Transport wraps base (http.DefaultTransport when nil) so each call gets a
+// client span, named after the method and host, under the span in the
+// request's context. Build requests with http.NewRequestWithContext for the
+// spans to join the caller's trace.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method + " " + r.URL.Host }))
+}
+
+// Start begins a child span of whatever span ctx carries. Finish it with End.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End finishes span, marking it failed when err is non-nil. A context
+// cancelled because the client went away is recorded but not marked failed.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !errors.Is(err, context.Canceled) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
@@ -131,7 +131,7 @@ func documentationHandler(w http.ResponseWriter, r *http.Request) {
                 <tr>
                     <td><code>format</code></td>
                     <td><code>svg</code></td>
-                    <td>Output format. Use <code>png</code> for social media sharing (LinkedIn/Twitter).</td>
+                    <td>Output format: <code>svg</code>, <code>png</code>, <code>webp</code> or <code>json</code>. Use <code>png</code> for social media sharing (LinkedIn/Twitter). When omitted, the <code>Accept</code> header picks the format.</td>
                 </tr>
                 <tr>
                     <td><code>title</code></td>
@@ -8,6 +8,8 @@ import (
 	"sort"
 	"time"
 
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 	"github.com/shurcooL/githubv4"
 	"golang.org/x/oauth2"
 )
@@ -100,27 +102,33 @@ type queryLangs struct {
 	} `graphql:"user(login: $username)"`
 }
 
-func FetchGitHubData(username, token string, location *time.Location) (DashboardData, error) {
+func FetchGitHubData(ctx context.Context, username, token string, location *time.Location) (DashboardData, error) {
 	// 1. Client Setup
 	baseClient := &http.Client{
 		Timeout: 60 * time.Second,
-		Transport: &http.Transport{TLSHandshakeTimeout: 15 * time.Second},
+		Transport: metrics.Transport("github_graphql", tracing.Transport(&http.Transport{TLSHandshakeTimeout: 15 * time.Second})),
 	}
-	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, baseClient)
+	clientCtx := context.WithValue(context.Background(), oauth2.HTTPClient, baseClient)
 	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	client := githubv4.NewClient(oauth2.NewClient(ctx, src))
+	client := githubv4.NewClient(oauth2.NewClient(clientCtx, src))
 
 	// 2. Run Query 1 (Stats)
 	var qStats queryStats
 	variables := map[string]interface{}{"username": githubv4.String(username)}
 
-	if err := client.Query(context.Background(), &qStats, variables); err != nil {
+	qctx, span := tracing.Start(ctx, "github.graphql stats")
+	err := client.Query(qctx, &qStats, variables)
+	tracing.End(span, err)
+	if err != nil {
 		return DashboardData{}, fmt.Errorf("GitHub Stats API Error: %v", err)
 	}
 
 	// 3. Run Query 2 (Languages)
 	var qLangs queryLangs
-	if err := client.Query(context.Background(), &qLangs, variables); err != nil {
+	qctx, span = tracing.Start(ctx, "github.graphql languages")
+	err = client.Query(qctx, &qLangs, variables)
+	tracing.End(span, err)
+	if err != nil {
 		return DashboardData{}, fmt.Errorf("GitHub Langs API Error: %v", err)
 	}
 	starSum := 0
@@ -2,88 +2,122 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"image/png"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"strings"
 	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	"github.com/go-chi/httprate"
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/cache"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/negotiate"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/server"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 	"github.com/joho/godotenv"
 )
 
+// dataCache holds fetched profiles, so repeated README views don't spend the
+// token's GraphQL rate limit. The TTL matches the Cache-Control we send.
+var dataCache *cache.Cache
+
 func main() {
 	err := godotenv.Load()
     if err != nil {
         log.Println("Error loading .env file")
     }
+	// A dashboard waits on two GraphQL queries and rsvg-convert.
+	cfg := server.Defaults("github-dashboard-api", "8003")
+	cfg.WriteTimeout = 2 * time.Minute
+	r := server.New(cfg)
+
 	if os.Getenv("GITHUB_TOKEN") == "" {
-        log.Fatal("❌ CRITICAL: GITHUB_TOKEN is missing from environment!")
+        slog.Error("❌ CRITICAL: GITHUB_TOKEN is missing from environment!")
+        os.Exit(1)
     }
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Heartbeat("/health"))
-	r.Use(middleware.Compress(5))
-	r.Use(httprate.Limit(
-		100,
-		1*time.Minute,
-		httprate.WithKeyFuncs(httprate.KeyByIP),
-	))
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-	}))
+	dataCache, err = cache.FromEnv(cache.Options{Namespace: "github", TTL: 2 * time.Hour, MaxBytes: 16 << 20})
+	if err != nil {
+		slog.Error("❌ Invalid cache settings", "err", err)
+		os.Exit(1)
+	}
+	metrics.RegisterCache(dataCache)
+	r.OnShutdown(dataCache.Close)
 
 	r.Get("/", documentationHandler)
 	r.Get("/api/github-stats", fetcherHandler)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8003"
+	if err := r.Run(); err != nil {
+		slog.Error("❌ Server failed", "err", err)
+		os.Exit(1)
 	}
-	log.Printf("🚀 Server running on port %s", port)
-	http.ListenAndServe(":"+port, r)
 }
 
 func fetcherHandler(w http.ResponseWriter, r *http.Request) {
 	username := r.URL.Query().Get("username")
 	timezone := r.URL.Query().Get("timezone")
-	format := r.URL.Query().Get("format") 
+	format := negotiate.Format(r, dashboardOffers)
 	title := r.URL.Query().Get("title")
 	if(title ==""){
 		title = "FullStack Developer"
 	}
-	if format == "" {
-		format = "svg"
-	}
+	w.Header().Add("Vary", "Accept")
 	if username == ""  || timezone == "" {
 		http.Error(w, "Missing query parameters", http.StatusBadRequest)
 		return
 	}
-	data, err := FetchGitHubData(username, os.Getenv("GITHUB_TOKEN"),resolveTimezone(timezone))
-	if err != nil {
-		fmt.Println(err)
-		return
+	location := resolveTimezone(timezone)
+	cacheKey := strings.ToLower(username) + ":" + location.String()
+	data, found := cache.GetJSON[DashboardData](dataCache, cacheKey)
+	if !found {
+		var err error
+		data, err = FetchGitHubData(r.Context(), username, os.Getenv("GITHUB_TOKEN"), location)
+		if err != nil {
+			logging.From(r.Context()).Error("GitHub fetch failed", "username", username, "err", err)
+			http.Error(w, "Failed to fetch GitHub data", http.StatusBadGateway)
+			return
+		}
+		cache.SetJSON(dataCache, cacheKey, data)
+		w.Header().Set("X-Cache", "MISS")
+	} else {
+		w.Header().Set("X-Cache", "HIT")
 	}
 	data.title = title
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=7200")
+		json.NewEncoder(w).Encode(data)
+		return
+	}
 	var buf bytes.Buffer
-		renderer := NewRenderer(&buf)
+		ctx, span := tracing.Start(r.Context(), "render dashboard")
+		renderer := NewRenderer(ctx, &buf)
 		renderer.Render(data)
-		if format == "png" {
+		span.End()
+		switch format {
+		case "png":
 			w.Header().Set("Content-Type", "image/png")
 			w.Header().Set("Cache-Control", "public, max-age=7200")
 			
-			if err := renderPNG(w, buf.Bytes()); err != nil {
-				log.Printf("PNG Encoding error: %v", err)
+			if err := renderPNG(r.Context(), w, buf.Bytes()); err != nil {
+				logging.From(r.Context()).Error("PNG encoding failed", "err", err)
 				http.Error(w, "Failed to generate PNG", 500)
 			}
-		} else {
+		case "webp":
+			w.Header().Set("Content-Type", "image/webp")
+			w.Header().Set("Cache-Control", "public, max-age=7200")
+
+			if err := renderWebP(r.Context(), w, buf.Bytes()); err != nil {
+				logging.From(r.Context()).Error("WebP encoding failed", "err", err)
+				http.Error(w, "Failed to generate WebP", 500)
+			}
+		default:
 			// Default: Stream the SVG directly
 			w.Header().Set("Content-Type", "image/svg+xml")
 			w.Header().Set("Cache-Control", "public, max-age=7200")
@@ -91,6 +125,15 @@ func fetcherHandler(w http.ResponseWriter, r *http.Request) {
 		}
 }
 
+// dashboardOffers lists the formats fetcherHandler can negotiate via the
+// Accept header, in order of preference.
+var dashboardOffers = []negotiate.Offer{
+	{Format: "svg", MediaType: "image/svg+xml"},
+	{Format: "png", MediaType: "image/png"},
+	{Format: "webp", MediaType: "image/webp"},
+	{Format: "json", MediaType: "application/json"},
+}
+
 func resolveTimezone(input string) *time.Location {
 	if input == "" {
 		return time.UTC
@@ -121,15 +164,38 @@ func resolveTimezone(input string) *time.Location {
 
 	return time.UTC
 }
-func renderPNG(w http.ResponseWriter, svgData []byte) error {
+func renderPNG(ctx context.Context, w http.ResponseWriter, svgData []byte) error {
     // Call the external 'rsvg-convert' tool
     // It reads from Stdin and writes to Stdout
-    cmd := exec.Command("rsvg-convert")
+    ctx, span := tracing.Start(ctx, "rsvg-convert")
+    cmd := exec.CommandContext(ctx, "rsvg-convert")
     cmd.Stdin = bytes.NewReader(svgData)
     cmd.Stdout = w // Stream output directly to the HTTP response
 
-    if err := cmd.Run(); err != nil {
+    err := cmd.Run()
+    tracing.End(span, err)
+    if err != nil {
         return fmt.Errorf("rsvg-convert failed: %w", err)
     }
     return nil
+}
+
+// renderWebP rasterizes through rsvg-convert and re-encodes the PNG as lossless WebP.
+func renderWebP(ctx context.Context, w http.ResponseWriter, svgData []byte) error {
+    var pngBuf bytes.Buffer
+    ctx, span := tracing.Start(ctx, "rsvg-convert")
+    cmd := exec.CommandContext(ctx, "rsvg-convert")
+    cmd.Stdin = bytes.NewReader(svgData)
+    cmd.Stdout = &pngBuf
+    err := cmd.Run()
+    tracing.End(span, err)
+    if err != nil {
+        return fmt.Errorf("rsvg-convert failed: %w", err)
+    }
+
+    img, err := png.Decode(&pngBuf)
+    if err != nil {
+        return fmt.Errorf("decode png: %w", err)
+    }
+    return nativewebp.Encode(w, img, nil)
 }
\ No newline at end of file
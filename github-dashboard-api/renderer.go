@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -57,8 +58,9 @@ type RepoItem struct { Name, Stars, Forks string }
 type ActivityItem struct { Action, Repo, Type string }
 
 // --- Renderer ---
-type Renderer struct { canvas *svg.SVG }
-func NewRenderer(w io.Writer) *Renderer { return &Renderer{canvas: svg.New(w)} }
+// Renderer carries the request's context so the avatar download joins its trace.
+type Renderer struct { ctx context.Context; canvas *svg.SVG }
+func NewRenderer(ctx context.Context, w io.Writer) *Renderer { return &Renderer{ctx: ctx, canvas: svg.New(w)} }
 
 func (r *Renderer) Render(data DashboardData) {
 	canvas := r.canvas
@@ -185,7 +187,7 @@ func (r *Renderer) drawProfilePanel(x, y, w, h int, data DashboardData) {
 		SecondaryColor: "#11011D", // Dark Purple for bg
 	}
 
-	DrawDitheredAvatar(r.canvas, 0, 0, virtualSize, virtualSize, config)
+	DrawDitheredAvatar(r.ctx, r.canvas, 0, 0, virtualSize, virtualSize, config)
 	r.canvas.Gend()
 
 	// 2. Text Info
@@ -4,7 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
@@ -12,14 +12,13 @@ import (
 	"time"
 	"unicode"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	"github.com/go-chi/httprate"
-
 	// "github.com/xuri/excelize/v2"
-	lru "github.com/hashicorp/golang-lru/v2"
 	_ "modernc.org/sqlite"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/cache"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/server"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 )
 
 type Institution struct {
@@ -29,8 +28,8 @@ type Institution struct {
 }
 
 var (
-	db    *sql.DB
-	cache *lru.Cache[string, []Institution]
+	db          *sql.DB
+	searchCache *cache.Cache
 )
 
 var synonyms = map[string]string{
@@ -107,48 +106,39 @@ var synonyms = map[string]string{
 }
 
 func main() {
+	r := server.New(server.Defaults("institutions-api", "8004"))
+
 	var err error
 	db, err = sql.Open("sqlite", "./institutions.db")
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("❌ Failed to open database", "err", err)
+		os.Exit(1)
 	}
-	defer db.Close()
 	if _, err := db.Exec("PRAGMA journal_mode = WAL;"); err != nil {
-		log.Println("⚠️ Failed to enable WAL mode:", err)
+		slog.Warn("⚠️ Failed to enable WAL mode", "err", err)
 	}
 	db.SetMaxOpenConns(10)
 	db.SetMaxIdleConns(5)
 
-	// Initialize in-memory LRU cache for institutions
-	cache, err = lru.New[string, []Institution](1000)
+	// Initialize the search cache (in-memory unless CACHE_URL says otherwise)
+	searchCache, err = cache.FromEnv(cache.Options{Namespace: "institutions", TTL: 24 * time.Hour, MaxBytes: 16 << 20})
 	if err != nil {
-		log.Fatalf("Failed to create cache: %v", err)
+		slog.Error("❌ Invalid cache settings", "err", err)
+		os.Exit(1)
 	}
+	metrics.RegisterCache(searchCache)
 
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Heartbeat("/health"))
-	r.Use(middleware.Compress(5))
-	r.Use(httprate.Limit(
-		100,
-		1*time.Minute,
-		httprate.WithKeyFuncs(httprate.KeyByIP),
-	))
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-	}))
+	// Close the database once in-flight queries have drained.
+	r.OnShutdown(db.Close)
+	r.OnShutdown(searchCache.Close)
 
 	r.Get("/", documentationHandler)
 	r.Get("/api/institutions", searchHandler)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8004"
+	if err := r.Run(); err != nil {
+		slog.Error("❌ Server failed", "err", err)
+		os.Exit(1)
 	}
-	log.Printf("🚀 Server running on port %s", port)
-	http.ListenAndServe(":"+port, r)
 }
 func sanitizeQuery(input string) string {
 	clean := strings.Map(func(r rune) rune {
@@ -173,14 +163,12 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
 	cacheKey := fmt.Sprintf("institutions:%s:limit:%s", strings.ToLower(query), limitStr)
 
-	if cache != nil {
-		if institutions, found := cache.Get(cacheKey); found {
-			w.Header().Set("X-Cache", "HIT")
-			w.Header().Set("Cache-Control", "public, max-age=3600")
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(institutions)
-			return
-		}
+	if institutions, found := cache.GetJSON[[]Institution](searchCache, cacheKey); found {
+		w.Header().Set("X-Cache", "HIT")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(institutions)
+		return
 	}
 	if limitStr == "" {
 		limitStr = "20"
@@ -226,8 +214,12 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		LIMIT %d
 	`, query, limit)
 
-	rows, err := db.Query(sqlQuery, query)
+	done := metrics.TimeQuery("search_institutions")
+	ctx, span := tracing.Start(r.Context(), "sqlite search_institutions")
+	rows, err := db.QueryContext(ctx, sqlQuery, query)
 	if err != nil {
+		done(err)
+		tracing.End(span, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -238,13 +230,13 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		rows.Scan(&i.ID, &i.Name, &i.State)
 		results = append(results, i)
 	}
+	done(rows.Err())
+	tracing.End(span, rows.Err())
 
 	if results == nil {
 		results = []Institution{}
 	}
-	if cache != nil {
-		cache.Add(cacheKey, results)
-	}
+	cache.SetJSON(searchCache, cacheKey, results)
 
 	w.Header().Set("X-Cache", "MISS")
 	w.Header().Set("Cache-Control", "public, max-age=3600")
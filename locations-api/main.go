@@ -4,12 +4,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"os"
 
 	// "encoding/json"
 	// "fmt"
 	"log"
+	"log/slog"
 	"net/http"
-	"os"
 
 	// "strconv"
 	"strings"
@@ -17,59 +18,51 @@ import (
 
 	// "unicode"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	"github.com/go-chi/httprate"
-	lru "github.com/hashicorp/golang-lru/v2"
 	_ "modernc.org/sqlite"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/cache"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/server"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 )
 
 var (
-	db    *sql.DB
-	cache *lru.Cache[string, []Location]
+	db          *sql.DB
+	searchCache *cache.Cache
 )
 
 func main() {
+	r := server.New(server.Defaults("locations-api", "8005"))
+
 	var err error
 	db, err = sql.Open("sqlite", "./locations.db")
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("❌ Failed to open database", "err", err)
+		os.Exit(1)
 	}
-	cache, err = lru.New[string, []Location](1000)
+	searchCache, err = cache.FromEnv(cache.Options{Namespace: "locations", TTL: 24 * time.Hour, MaxBytes: 16 << 20})
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("❌ Invalid cache settings", "err", err)
+		os.Exit(1)
 	}
-	defer db.Close()
+	metrics.RegisterCache(searchCache)
 	if _, err := db.Exec("PRAGMA journal_mode = WAL;"); err != nil {
-		log.Println("⚠️ Failed to enable WAL mode:", err)
+		slog.Warn("⚠️ Failed to enable WAL mode", "err", err)
 	}
 	db.SetMaxOpenConns(10)
 	db.SetMaxIdleConns(5)
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Heartbeat("/health"))
-	r.Use(middleware.Compress(5))
-	r.Use(httprate.Limit(
-		100,
-		1*time.Minute,
-		httprate.WithKeyFuncs(httprate.KeyByIP),
-	))
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-	}))
+	// Close the database once in-flight queries have drained.
+	r.OnShutdown(db.Close)
+	r.OnShutdown(searchCache.Close)
 
 	r.Get("/", documentationHandler)
 	r.Get("/api/locations", searchHandler)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8005"
+	if err := r.Run(); err != nil {
+		slog.Error("❌ Server failed", "err", err)
+		os.Exit(1)
 	}
-	log.Printf("🚀 Server running on port %s", port)
-	http.ListenAndServe(":"+port, r)
 }
 func documentationHandler(w http.ResponseWriter, r *http.Request) {
 	const apiDocsHTML = `
@@ -231,11 +224,9 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 
 	cacheKey := searchType + ":" + searchValue
 
-	if cache != nil {
-		if locations, found := cache.Get(cacheKey); found {
-			respondWithJSON(w, locations, "HIT")
-			return
-		}
+	if locations, found := cache.GetJSON[[]Location](searchCache, cacheKey); found {
+		respondWithJSON(w, locations, "HIT")
+		return
 	}
 
 	query := fmt.Sprintf(`
@@ -247,9 +238,13 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
         ORDER BY length(%s) ASC 
         LIMIT 10`, dbColumn, dbColumn)
 
-	rows, err := db.Query(query, "%"+searchValue+"%")
+	done := metrics.TimeQuery("search_" + searchType)
+	ctx, span := tracing.Start(r.Context(), "sqlite search_"+searchType)
+	rows, err := db.QueryContext(ctx, query, "%"+searchValue+"%")
 	if err != nil {
-		log.Printf("DB Error: %v", err)
+		done(err)
+		tracing.End(span, err)
+		logging.From(r.Context()).Error("location query failed", "err", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
@@ -260,15 +255,15 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var loc Location
 		if err := rows.Scan(&loc.City, &loc.State, &loc.Country); err != nil {
-			log.Printf("Scan Error: %v", err)
+			logging.From(r.Context()).Error("location scan failed", "err", err)
 			continue
 		}
 		results = append(results, loc)
 	}
+	done(rows.Err())
+	tracing.End(span, rows.Err())
 
-	if cache != nil {
-		cache.Add(cacheKey, results)
-	}
+	cache.SetJSON(searchCache, cacheKey, results)
 	respondWithJSON(w, results, "MISS")
 }
 
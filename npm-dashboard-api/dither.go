@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	_ "image/jpeg"
@@ -9,6 +10,8 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 	svg "github.com/ajstarks/svgo"
 )
 
@@ -25,8 +28,16 @@ type DitherConfig struct {
 	SecondaryColor string
 }
 
-func DrawDitheredAvatar(s *svg.SVG, startX, startY, displayWidth, displayHeight int, cfg DitherConfig) {
-	resp, err := http.Get(cfg.Url)
+var avatarClient = &http.Client{Transport: metrics.Transport("npm_avatar", tracing.Transport(nil))}
+
+func DrawDitheredAvatar(ctx context.Context, s *svg.SVG, startX, startY, displayWidth, displayHeight int, cfg DitherConfig) {
+	ctx, span := tracing.Start(ctx, "dither avatar")
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Url, nil)
+	if err != nil { return }
+	resp, err := avatarClient.Do(req)
 	if err != nil { return }
 	defer resp.Body.Close()
 
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 )
 
 // --- Structs ---
@@ -51,14 +55,20 @@ type npmRangeResp struct {
 	} `json:"downloads"`
 }
 
-func FetchNPMData(username string) (NpmDashboardData, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+func FetchNPMData(ctx context.Context, username string) (NpmDashboardData, error) {
+	client := &http.Client{Timeout: 10 * time.Second, Transport: metrics.Transport("npm_registry", tracing.Transport(nil))}
+	// Requests carry ctx so they show up in the caller's trace.
+	get := func(url string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil { return nil, err }
+		return client.Do(req)
+	}
 	data := NpmDashboardData{Username: username}
 
 	// 1. Search Packages (Max 250)
 	// We do NOT filter by publisher, so Bot releases are INCLUDED.
 	url := fmt.Sprintf("https://registry.npmjs.org/-/v1/search?text=maintainer:%s&size=250", username)
-	resp, err := client.Get(url)
+	resp, err := get(url)
 	if err != nil { return data, err }
 	defer resp.Body.Close()
 
@@ -110,7 +120,7 @@ func FetchNPMData(username string) (NpmDashboardData, error) {
 		subset := packageNames[i:end]
 		bulkUrl := fmt.Sprintf("https://api.npmjs.org/downloads/point/last-year/%s", strings.Join(subset, ","))
 		
-		if resp, err := client.Get(bulkUrl); err == nil {
+		if resp, err := get(bulkUrl); err == nil {
 			var bulkResult map[string]interface{}
 			_ = json.NewDecoder(resp.Body).Decode(&bulkResult)
 			resp.Body.Close()
@@ -157,7 +167,7 @@ func FetchNPMData(username string) (NpmDashboardData, error) {
 		topPkg := data.TopPackages[0].Name
 		rangeUrl := fmt.Sprintf("https://api.npmjs.org/downloads/range/last-year/%s", topPkg)
 		
-		if resp, err := client.Get(rangeUrl); err == nil {
+		if resp, err := get(rangeUrl); err == nil {
 			var rangeRes npmRangeResp
 			if err := json.NewDecoder(resp.Body).Decode(&rangeRes); err == nil {
 				// Compress 365 days into ~40 points for the SVG
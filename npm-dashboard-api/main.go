@@ -2,82 +2,142 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"image"
 	"image/png"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	"github.com/go-chi/httprate"
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/cache"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/logging"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/metrics"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/negotiate"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/server"
+	"github.com/Kishan-Agarwal-28/utils_hub/common/tracing"
 	"github.com/joho/godotenv"
 	"github.com/srwiley/oksvg"
 	"github.com/srwiley/rasterx"
 )
 
+// dataCache holds fetched registry stats, so a popular README badge doesn't
+// re-query npm on every view.
+var dataCache *cache.Cache
+
 func main() {
 	_ = godotenv.Load()
 
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Compress(5))
-	
-	// Rate Limit: 100 reqs/min (NPM API is generous but let's be safe)
-	r.Use(httprate.Limit(100, 1*time.Minute, httprate.WithKeyFuncs(httprate.KeyByIP)))
-	r.Use(cors.Handler(cors.Options{AllowedOrigins: []string{"*"}}))
+	// A dashboard waits on the registry search and batches of download counts.
+	cfg := server.Defaults("npm-dashboard-api", "8006")
+	cfg.WriteTimeout = 2 * time.Minute
+	r := server.New(cfg)
+
+	var err error
+	dataCache, err = cache.FromEnv(cache.Options{Namespace: "npm", TTL: 2 * time.Hour, MaxBytes: 16 << 20})
+	if err != nil {
+		slog.Error("❌ Invalid cache settings", "err", err)
+		os.Exit(1)
+	}
+	metrics.RegisterCache(dataCache)
+	r.OnShutdown(dataCache.Close)
 
 	r.Get("/", documentationHandler)
 	
 	r.Get("/api/npm-stats", fetcherHandler)
 
-	port := os.Getenv("PORT")
-	if port == "" { port = "8006" }
-	
-	log.Printf("📦 NPM Server running on port %s", port)
-	http.ListenAndServe(":"+port, r)
+	if err := r.Run(); err != nil {
+		slog.Error("❌ Server failed", "err", err)
+		os.Exit(1)
+	}
 }
 
 func fetcherHandler(w http.ResponseWriter, r *http.Request) {
 	username := r.URL.Query().Get("username")
-	format := r.URL.Query().Get("format")
-	if format == "" { format = "svg" }
+	format := negotiate.Format(r, dashboardOffers)
+	w.Header().Add("Vary", "Accept")
 
 	if username == "" {
 		http.Error(w, "Missing 'username' parameter", 400)
 		return
 	}
 
-	// 1. Fetch NPM Data
-	data, err := FetchNPMData(username)
-	if err != nil {
-		log.Printf("NPM Fetch Error: %v", err)
-		http.Error(w, "Failed to fetch NPM data", 500)
+	// 1. Fetch NPM Data (or reuse a recent fetch)
+	cacheKey := strings.ToLower(username)
+	data, found := cache.GetJSON[NpmDashboardData](dataCache, cacheKey)
+	if !found {
+		var err error
+		data, err = FetchNPMData(r.Context(), username)
+		if err != nil {
+			logging.From(r.Context()).Error("npm fetch failed", "username", username, "err", err)
+			http.Error(w, "Failed to fetch NPM data", 500)
+			return
+		}
+		cache.SetJSON(dataCache, cacheKey, data)
+		w.Header().Set("X-Cache", "MISS")
+	} else {
+		w.Header().Set("X-Cache", "HIT")
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=7200")
+		json.NewEncoder(w).Encode(data)
 		return
 	}
 
 	// 2. Render
 	var buf bytes.Buffer
-	renderer := NewRenderer(&buf)
+	ctx, span := tracing.Start(r.Context(), "render dashboard")
+	renderer := NewRenderer(ctx, &buf)
 	renderer.Render(data)
+	span.End()
 
 	// 3. Output
-	if format == "png" {
+	switch format {
+	case "png":
 		w.Header().Set("Content-Type", "image/png")
 		w.Header().Set("Cache-Control", "public, max-age=7200")
-		renderPNG(w, buf.Bytes())
-	} else {
+		renderPNG(r.Context(), w, buf.Bytes())
+	case "webp":
+		w.Header().Set("Content-Type", "image/webp")
+		w.Header().Set("Cache-Control", "public, max-age=7200")
+		renderWebP(r.Context(), w, buf.Bytes())
+	default:
 		w.Header().Set("Content-Type", "image/svg+xml")
 		w.Header().Set("Cache-Control", "public, max-age=7200")
 		w.Write(buf.Bytes())
 	}
 }
 
+// dashboardOffers lists the formats fetcherHandler can negotiate via the
+// Accept header, in order of preference.
+var dashboardOffers = []negotiate.Offer{
+	{Format: "svg", MediaType: "image/svg+xml"},
+	{Format: "png", MediaType: "image/png"},
+	{Format: "webp", MediaType: "image/webp"},
+	{Format: "json", MediaType: "application/json"},
+}
+
 // Reuse the exact same PNG renderer from the GitHub project
-func renderPNG(w http.ResponseWriter, svgData []byte) error {
+func renderPNG(ctx context.Context, w http.ResponseWriter, svgData []byte) error {
+	_, span := tracing.Start(ctx, "rasterize png")
+	err := png.Encode(w, rasterizeSVG(svgData))
+	tracing.End(span, err)
+	return err
+}
+
+func renderWebP(ctx context.Context, w http.ResponseWriter, svgData []byte) error {
+	_, span := tracing.Start(ctx, "rasterize webp")
+	err := nativewebp.Encode(w, rasterizeSVG(svgData), nil)
+	tracing.End(span, err)
+	return err
+}
+
+func rasterizeSVG(svgData []byte) *image.RGBA {
 	icon, _ := oksvg.ReadIconStream(bytes.NewReader(svgData))
 	wInt, hInt := int(icon.ViewBox.W), int(icon.ViewBox.H)
 	icon.SetTarget(0, 0, float64(wInt), float64(hInt))
@@ -85,5 +145,5 @@ func renderPNG(w http.ResponseWriter, svgData []byte) error {
 	scanner := rasterx.NewScannerGV(wInt, hInt, rgba, rgba.Bounds())
 	raster := rasterx.NewDasher(wInt, hInt, scanner)
 	icon.Draw(raster, 1.0)
-	return png.Encode(w, rgba)
+	return rgba
 }
\ No newline at end of file
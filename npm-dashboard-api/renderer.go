@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 
@@ -15,8 +16,9 @@ const (
 	FontFamily = "'Courier New', Courier, monospace"
 )
 
-type Renderer struct { canvas *svg.SVG }
-func NewRenderer(w io.Writer) *Renderer { return &Renderer{canvas: svg.New(w)} }
+// Renderer carries the request's context so the avatar download joins its trace.
+type Renderer struct { ctx context.Context; canvas *svg.SVG }
+func NewRenderer(ctx context.Context, w io.Writer) *Renderer { return &Renderer{ctx: ctx, canvas: svg.New(w)} }
 
 func (r *Renderer) Render(data NpmDashboardData) {
 	canvas := r.canvas
@@ -153,7 +155,7 @@ func (r *Renderer) drawProfilePanel(x, y, w, h int, data NpmDashboardData) {
 		Url: data.AvatarURL, GridSize: 1, Contrast: 1.2, Brightness: 0.05,
 		PrimaryColor: "#f5f5f5", SecondaryColor: "#11011D",
 	}
-	DrawDitheredAvatar(r.canvas, 0, 0, virtualSize, virtualSize, config)
+	DrawDitheredAvatar(r.ctx, r.canvas, 0, 0, virtualSize, virtualSize, config)
 	r.canvas.Gend()
 
 	textY := imgY + avatarSize + 25
@@ -1,26 +1,53 @@
 #!/bin/bash
 
+# Every process started here, so signals can be forwarded to all of them.
+pids=()
+start() {
+  "$@" &
+  pids+=($!)
+}
+
+# Forward SIGTERM/SIGINT (docker stop, Ctrl-C) so each service drains its
+# in-flight requests and closes its database before the container exits.
+stop() {
+  echo "🛑 Stopping Utility Hub..."
+  kill -TERM "${pids[@]}" 2>/dev/null
+  wait "${pids[@]}"
+  exit 0
+}
+trap stop TERM INT
+
 # 1. Avatars API -> 8000
-PORT=8000 ./bin/avatars-api &
+PORT=8000 start ./bin/avatars-api
 
 # 2. Calendars API -> 8001
-PORT=8001 node ./calendars-api/dist/index.js &
+PORT=8001 start node ./calendars-api/dist/index.js
 
 # 3. Charts API -> 8002
-PORT=8002 ./bin/charts-api &
+PORT=8002 start ./bin/charts-api
 
 # 4. GitHub Dashboard API -> 8003
-PORT=8003 ./bin/github-stats &
+PORT=8003 start ./bin/github-stats
 
 # 5. Institutions API -> 8004
-PORT=8004 ./bin/institutions-api &
+PORT=8004 start ./bin/institutions-api
 
 # 6. Locations API -> 8005
-PORT=8005 ./bin/locations-api &
+PORT=8005 start ./bin/locations-api
 
 # 7. NPM Dashboard API -> 8006
-PORT=8006 ./bin/npm-stats &
+PORT=8006 start ./bin/npm-stats
 
 # Start Caddy on Port 7999 (The Public Access Point)
 echo "🚀 Starting Utility Hub on port 7999..."
-caddy run --config Caddyfile --adapter caddyfile
\ No newline at end of file
+start caddy run --config Caddyfile --adapter caddyfile
+
+# If any process dies on its own (a port already bound, a crash), stop the
+# rest and exit non-zero so the orchestrator restarts the container instead
+# of leaving it half up.
+wait -n
+status=$?
+echo "❌ A process exited with status $status, stopping Utility Hub..."
+kill -TERM "${pids[@]}" 2>/dev/null
+wait "${pids[@]}"
+exit $(( status == 0 ? 1 : status ))